@@ -73,7 +73,7 @@ func main() {
 	}
 
 	// Step 5: Now migrate all tables with proper foreign keys
-	err = storage.DB.AutoMigrate(&models.Post{}, &models.Comment{}, &models.PostLike{})
+	err = storage.DB.AutoMigrate(storage.AllModels()...)
 	if err != nil {
 		log.Fatalf("Failed to migrate remaining tables: %v", err)
 	}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// Settings read from the environment at startup. Defaults suit local development.
+var (
+	// ReportHideThreshold is how many distinct users must report a post or
+	// comment before it is hidden pending moderator review
+	ReportHideThreshold = envInt("REPORT_HIDE_THRESHOLD", 5)
+
+	// Content policy limits for new posts and comments. Accounts younger than
+	// NewAccountDays get the stricter NewAccountMaxLinks; going over either
+	// link limit queues the content for review.
+	MaxLinks           = envInt("POLICY_MAX_LINKS", 5)
+	NewAccountDays     = envInt("POLICY_NEW_ACCOUNT_DAYS", 3)
+	NewAccountMaxLinks = envInt("POLICY_NEW_ACCOUNT_MAX_LINKS", 1)
+
+	// DuplicateWindowHours is how far back identical content counts as a
+	// duplicate. Reposting your own content is blocked, someone else's is flagged.
+	DuplicateWindowHours = envInt("POLICY_DUPLICATE_WINDOW_HOURS", 24)
+
+	// TrashRetentionDays is how long deleted posts, comments and users can be
+	// restored before the purge job removes them for good
+	TrashRetentionDays = envInt("TRASH_RETENTION_DAYS", 30)
+
+	// RealtimeBackend is "memory" for a single instance or "postgres" to share
+	// live events between instances with LISTEN/NOTIFY
+	RealtimeBackend = envString("REALTIME_BACKEND", "memory")
+
+	// WebSocketMaxConnections is how many live thread connections one user may
+	// have open on each instance
+	WebSocketMaxConnections = envInt("WS_MAX_CONNECTIONS_PER_USER", 5)
+
+	// FeedCacheTTLSeconds enables the materialized home feed: each user's
+	// feed is rebuilt at most this often. 0 computes every feed on read.
+	FeedCacheTTLSeconds = envInt("FEED_CACHE_TTL_SECONDS", 0)
+
+	// WebhookFailureLimit is how many delivery attempts to a webhook may fail
+	// in a row before it is disabled
+	WebhookFailureLimit = envInt("WEBHOOK_FAILURE_LIMIT", 20)
+)
+
+func envInt(name string, fallback int) int {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q, using %d", name, value, fallback)
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,18 @@
+package config
+
+var (
+	// SMTP server for outgoing email. Email is skipped with a log line while
+	// SMTP_EMAIL or SMTP_PASSWORD is unset.
+	SMTPHost     = envString("SMTP_HOST", "smtp.gmail.com")
+	SMTPPort     = envString("SMTP_PORT", "587")
+	SMTPEmail    = envString("SMTP_EMAIL", "")
+	SMTPPassword = envString("SMTP_PASSWORD", "")
+
+	// APIURL is where this backend is reachable from email links, SiteURL
+	// is the frontend
+	APIURL  = envString("API_URL", "http://localhost:8080")
+	SiteURL = envString("SITE_URL", "http://localhost:3000")
+
+	// EmailSigningKey signs the one-click unsubscribe links in emails
+	EmailSigningKey = envString("EMAIL_SIGNING_KEY", "change-this-in-production")
+)
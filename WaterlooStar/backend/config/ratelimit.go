@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimit allows Requests per Window, with bursts of up to Requests
+type RateLimit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// RoutePolicy holds the limits for one route. NewAccount applies to accounts
+// younger than NewAccountDays.
+type RoutePolicy struct {
+	Default    RateLimit
+	NewAccount RateLimit
+}
+
+// Rate limited routes
+const (
+	RoutePostCreate    = "post.create"
+	RouteCommentCreate = "comment.create"
+	RoutePostLike      = "post.like"
+	RouteMessageCreate = "message.create"
+	RouteAuthLogin     = "auth.login"    // Public, limited per IP
+	RouteAuthRegister  = "auth.register" // Public, limited per IP
+)
+
+var (
+	// RateLimitStore is "memory" or "postgres", see storage.InitRateLimitStore
+	RateLimitStore = envString("RATE_LIMIT_STORE", "memory")
+
+	// RateLimits are set per route as "<requests>/<window>", e.g.
+	// RATE_LIMIT_POST_CREATE=5/10m and RATE_LIMIT_POST_CREATE_NEW=2/10m
+	RateLimits = map[string]RoutePolicy{
+		RoutePostCreate: {
+			Default:    envRateLimit("RATE_LIMIT_POST_CREATE", RateLimit{5, 10 * time.Minute}),
+			NewAccount: envRateLimit("RATE_LIMIT_POST_CREATE_NEW", RateLimit{2, 10 * time.Minute}),
+		},
+		RouteCommentCreate: {
+			Default:    envRateLimit("RATE_LIMIT_COMMENT_CREATE", RateLimit{30, 10 * time.Minute}),
+			NewAccount: envRateLimit("RATE_LIMIT_COMMENT_CREATE_NEW", RateLimit{10, 10 * time.Minute}),
+		},
+		RoutePostLike: {
+			Default:    envRateLimit("RATE_LIMIT_POST_LIKE", RateLimit{120, time.Minute}),
+			NewAccount: envRateLimit("RATE_LIMIT_POST_LIKE_NEW", RateLimit{30, time.Minute}),
+		},
+		RouteMessageCreate: {
+			Default:    envRateLimit("RATE_LIMIT_MESSAGE_CREATE", RateLimit{60, 10 * time.Minute}),
+			NewAccount: envRateLimit("RATE_LIMIT_MESSAGE_CREATE_NEW", RateLimit{20, 10 * time.Minute}),
+		},
+		// Guests have no account age, so only Default applies to these
+		RouteAuthLogin: {
+			Default: envRateLimit("RATE_LIMIT_AUTH_LOGIN", RateLimit{10, 15 * time.Minute}),
+		},
+		RouteAuthRegister: {
+			Default: envRateLimit("RATE_LIMIT_AUTH_REGISTER", RateLimit{5, time.Hour}),
+		},
+	}
+)
+
+func envString(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envRateLimit(name string, fallback RateLimit) RateLimit {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	limit, err := parseRateLimit(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s=%q (%v), using %d/%s", name, value, err, fallback.Requests, fallback.Window)
+		return fallback
+	}
+	return limit
+}
+
+func parseRateLimit(value string) (RateLimit, error) {
+	requests, window, ok := strings.Cut(value, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("expected <requests>/<window>")
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n <= 0 {
+		return RateLimit{}, fmt.Errorf("requests must be a positive number")
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return RateLimit{}, fmt.Errorf("window must be a positive duration like 10m")
+	}
+	return RateLimit{Requests: n, Window: d}, nil
+}
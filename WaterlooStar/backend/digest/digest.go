@@ -0,0 +1,130 @@
+// Package digest builds the daily and weekly email digests: the top posts of
+// the period among what a user follows, ranked by likes, comments and views.
+package digest
+
+import (
+	"WaterlooStar/backend/feed"
+	"WaterlooStar/backend/mail"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/notify"
+	"WaterlooStar/backend/visibility"
+	"html/template"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxPosts is how many posts one digest lists
+const maxPosts = 10
+
+// score ranks posts for the digest. Comments only count while visible.
+const score = "posts.likes * 3 + posts.views / 10.0 + 2 * (SELECT COUNT(*) FROM comments " +
+	"WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL AND NOT comments.is_hidden)"
+
+// Item is one post in a digest
+type Item struct {
+	Post     models.Post
+	Comments int64
+	Excerpt  string
+	URL      string
+}
+
+// Period returns how much time a digest of the given frequency covers
+func Period(frequency string) time.Duration {
+	if frequency == models.DigestWeekly {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Build ranks the posts published in [since, until) that the user can see,
+// leaving out their own posts. Like the home feed, it only takes the users,
+// sections and tags they follow; users who follow nothing get all posts.
+func Build(db *gorm.DB, pref *models.EmailPreference, since, until time.Time) ([]Item, error) {
+	viewer := visibility.Viewer{UserID: pref.UserID}
+	query := db.Scopes(viewer.Posts).
+		Where("posts.published_at >= ? AND posts.published_at < ?", since, until).
+		Where("posts.author_id <> ? AND NOT posts.is_hidden", pref.UserID)
+	var follows int64
+	if err := db.Model(&models.Follow{}).Where("user_id = ?", pref.UserID).Count(&follows).Error; err != nil {
+		return nil, err
+	}
+	if follows > 0 {
+		query = query.Scopes(feed.Followed(pref.UserID))
+	}
+
+	var posts []models.Post
+	err := query.Order(score + " DESC").Order("posts.published_at desc").Limit(maxPosts).Find(&posts).Error
+	if err != nil || len(posts) == 0 {
+		return nil, err
+	}
+	viewer.AdjustLikeCounts(db, posts)
+
+	postIDs := make([]uint, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+	var counts []struct {
+		PostID uint
+		Count  int64
+	}
+	db.Model(&models.Comment{}).Scopes(viewer.Comments).Select("post_id, COUNT(*) AS count").
+		Where("post_id IN ?", postIDs).Group("post_id").Scan(&counts)
+	comments := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		comments[c.PostID] = c.Count
+	}
+
+	items := make([]Item, len(posts))
+	for i, post := range posts {
+		items[i] = Item{
+			Post:     post,
+			Comments: comments[post.ID],
+			Excerpt:  notify.Excerpt(post.Content, 200),
+			URL:      notify.PostURL(post.Section, post.ID),
+		}
+	}
+	return items, nil
+}
+
+var digestEmail = template.Must(template.New("digest").Parse(`<html>
+<body style="font-family: Arial, sans-serif;">
+	<h2 style="color: #d4a574;">{{.Title}}</h2>
+	{{range .Items}}
+	<div style="margin-bottom: 20px;">
+		<a href="{{.URL}}" style="color: #333; font-size: 16px; font-weight: bold;">{{.Post.Title}}</a>
+		<div style="color: #888; font-size: 12px;">{{.Post.Section}} · {{.Post.Author}} · {{.Post.Likes}} likes · {{.Comments}} comments</div>
+		<p style="color: #444;">{{.Excerpt}}</p>
+	</div>
+	{{end}}
+	<p style="color: #888; font-size: 12px;">You get this {{.Frequency}} digest because you subscribed to it.
+		<a href="{{.UnsubscribeURL}}" style="color: #888;">Unsubscribe from digests</a>.</p>
+</body>
+</html>
+`))
+
+// Message renders a digest email for the user
+func Message(user *models.User, frequency string, items []Item) (mail.Message, error) {
+	title := "Top posts today"
+	if frequency == models.DigestWeekly {
+		title = "Top posts this week"
+	}
+
+	var html strings.Builder
+	err := digestEmail.Execute(&html, map[string]interface{}{
+		"Title":          title,
+		"Items":          items,
+		"Frequency":      frequency,
+		"UnsubscribeURL": mail.UnsubscribeURL(user.ID, mail.UnsubscribeDigest),
+	})
+	if err != nil {
+		return mail.Message{}, err
+	}
+	return mail.Message{
+		To:      user.Email,
+		Subject: title + " - Student Community Forum",
+		HTML:    html.String(),
+		Headers: mail.UnsubscribeHeaders(user.ID, mail.UnsubscribeDigest),
+	}, nil
+}
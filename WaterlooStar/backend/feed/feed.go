@@ -0,0 +1,254 @@
+// Package feed builds a user's home feed from the users, sections and tags
+// they follow. Feeds are computed on read by fanning out over the follows
+// table. With FEED_CACHE_TTL_SECONDS set, the top of each feed is also
+// materialized into feed_entries and later pages are read from there until it
+// goes stale.
+package feed
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/visibility"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Feed orders
+const (
+	SortNew = "new" // Newest first
+	SortTop = "top" // Highest score, decaying with age
+)
+
+const (
+	// cacheSize is how many entries a materialized feed holds
+	cacheSize = 500
+	// topWindow limits score ranking to recent posts; older ones have
+	// decayed below anything worth showing
+	topWindow = 14 * 24 * time.Hour
+)
+
+// score ranks posts for SortTop: engagement divided by age in hours at the
+// reference time, so that a page computed later is still ordered like the
+// first one. It is a float8 so that cursors round-trip it exactly. Comments
+// only count while visible.
+const score = "((posts.likes * 3 + posts.views / 10.0 + 2 * (SELECT COUNT(*) FROM comments " +
+	"WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL AND NOT comments.is_hidden) + 1) / " +
+	"POWER(GREATEST(EXTRACT(EPOCH FROM (?::timestamptz - posts.published_at)) / 3600, 0) + 2, 1.5))::float8"
+
+// ErrInvalidCursor is returned for cursors that were not made by this package
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ValidSort reports whether sort is a known feed order
+func ValidSort(sort string) bool {
+	return sort == SortNew || sort == SortTop
+}
+
+// Entry is one ranked post
+type Entry struct {
+	PostID      uint
+	PublishedAt time.Time
+	Score       float64
+}
+
+// cursor is where the next page starts. At is the reference time of the
+// scores, kept across pages so that SortTop pages do not shift.
+type cursor struct {
+	At          time.Time `json:"at"`
+	PublishedAt time.Time `json:"t"`
+	Score       float64   `json:"s,omitempty"`
+	PostID      uint      `json:"id"`
+}
+
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.PostID == 0 || c.At.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Followed scopes a posts query to what userID follows. Anonymous posts only
+// reach a feed through sections and tags, otherwise following someone would
+// reveal which anonymous posts are theirs. Tags are matched case-insensitively.
+func Followed(userID uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(posts.author_id IN (SELECT followed_id FROM follows WHERE user_id = ? AND kind = ?) AND NOT posts.is_anonymous) "+
+			"OR posts.section IN (SELECT name FROM follows WHERE user_id = ? AND kind = ?) "+
+			"OR EXISTS (SELECT 1 FROM follows WHERE follows.user_id = ? AND follows.kind = ? AND follows.name IN "+
+			"(SELECT btrim(tag) FROM unnest(string_to_array(lower(posts.tags), ',')) AS tag))",
+			userID, models.FollowUser, userID, models.FollowSection, userID, models.FollowTag)
+	}
+}
+
+// Page returns the post IDs of the feed page after the cursor token in order,
+// and the token of the next page, which is empty at the end. The posts still
+// need loading through viewer.Posts: cached entries may have been hidden or
+// deleted since, which only makes that page shorter.
+func Page(db *gorm.DB, viewer visibility.Viewer, sort, token string, limit int) ([]uint, string, error) {
+	var c *cursor
+	if token != "" {
+		var err error
+		if c, err = decodeCursor(token); err != nil {
+			return nil, "", err
+		}
+	}
+	at := time.Now()
+	if c != nil {
+		at = c.At
+	}
+
+	var entries []Entry
+	var err error
+	cached := false
+	if config.FeedCacheTTLSeconds > 0 {
+		var cachedAt time.Time
+		if entries, cachedAt, cached, err = fromCache(db, viewer, sort, c, limit); err != nil {
+			return nil, "", err
+		}
+		if cached {
+			at = cachedAt
+		}
+	}
+	if !cached {
+		if entries, err = rank(db, viewer, sort, at, c, limit+1); err != nil {
+			return nil, "", err
+		}
+	}
+
+	next := ""
+	if len(entries) > limit {
+		entries = entries[:limit]
+		last := entries[limit-1]
+		next = cursor{At: at, PublishedAt: last.PublishedAt, Score: last.Score, PostID: last.PostID}.encode()
+	}
+	ids := make([]uint, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.PostID
+	}
+	return ids, next, nil
+}
+
+// rank is the fan-out on read: it ranks the followed posts the viewer can
+// see, starting after c
+func rank(db *gorm.DB, viewer visibility.Viewer, sort string, at time.Time, c *cursor, limit int) ([]Entry, error) {
+	inner := db.Model(&models.Post{}).Scopes(viewer.Posts, Followed(viewer.UserID))
+	if sort == SortTop {
+		inner = inner.Select("posts.id AS post_id, posts.published_at, "+score+" AS score", at).
+			Where("posts.published_at > ? AND posts.published_at <= ?", at.Add(-topWindow), at)
+	} else {
+		inner = inner.Select("posts.id AS post_id, posts.published_at, 0 AS score")
+	}
+
+	query := db.Table("(?) AS ranked", inner)
+	query = after(query, sort, c)
+	var entries []Entry
+	err := query.Order(order(sort)).Limit(limit).Scan(&entries).Error
+	return entries, err
+}
+
+// after continues a ranked query from the cursor
+func after(query *gorm.DB, sort string, c *cursor) *gorm.DB {
+	if c == nil {
+		return query
+	}
+	if sort == SortTop {
+		return query.Where("score < ? OR (score = ? AND post_id < ?)", c.Score, c.Score, c.PostID)
+	}
+	return query.Where("published_at < ? OR (published_at = ? AND post_id < ?)", c.PublishedAt, c.PublishedAt, c.PostID)
+}
+
+func order(sort string) string {
+	if sort == SortTop {
+		return "score desc, post_id desc"
+	}
+	return "published_at desc, post_id desc"
+}
+
+// fromCache reads a page from the materialized feed, rebuilding it first when
+// a first page is requested and it is stale, and returns the reference time
+// of its scores. It reports false when the page has to be computed on read
+// instead: the cache was rebuilt since the cursor was made, or the page runs
+// past the end of a full cache.
+func fromCache(db *gorm.DB, viewer visibility.Viewer, sort string, c *cursor, limit int) ([]Entry, time.Time, bool, error) {
+	var state models.FeedCache
+	err := db.Where("user_id = ? AND sort = ?", viewer.UserID, sort).Take(&state).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, time.Time{}, false, err
+	}
+	ttl := time.Duration(config.FeedCacheTTLSeconds) * time.Second
+	if c == nil && (err != nil || time.Since(state.RefreshedAt) > ttl) {
+		if state, err = refresh(db, viewer, sort); err != nil {
+			return nil, time.Time{}, false, err
+		}
+	} else if err != nil || (c != nil && sort == SortTop && !state.At.Equal(c.At)) {
+		return nil, time.Time{}, false, nil
+	}
+
+	entriesOf := db.Model(&models.FeedEntry{}).Where("user_id = ? AND sort = ?", viewer.UserID, sort).Session(&gorm.Session{})
+	var entries []Entry
+	err = after(entriesOf, sort, c).Select("post_id, published_at, score").
+		Order(order(sort)).Limit(limit + 1).Scan(&entries).Error
+	if err != nil {
+		return nil, time.Time{}, false, err
+	}
+	if len(entries) <= limit {
+		var count int64
+		if err := entriesOf.Count(&count).Error; err != nil {
+			return nil, time.Time{}, false, err
+		}
+		if count >= cacheSize {
+			return nil, time.Time{}, false, nil
+		}
+	}
+	return entries, state.At, true, nil
+}
+
+// refresh rebuilds the materialized feed from the fan-out query
+func refresh(db *gorm.DB, viewer visibility.Viewer, sort string) (models.FeedCache, error) {
+	now := time.Now()
+	state := models.FeedCache{UserID: viewer.UserID, Sort: sort, At: now, RefreshedAt: now}
+	entries, err := rank(db, viewer, sort, now, nil, cacheSize)
+	if err != nil {
+		return state, err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND sort = ?", viewer.UserID, sort).Delete(&models.FeedEntry{}).Error; err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			rows := make([]models.FeedEntry, len(entries))
+			for i, entry := range entries {
+				rows[i] = models.FeedEntry{UserID: viewer.UserID, Sort: sort, PostID: entry.PostID, PublishedAt: entry.PublishedAt, Score: entry.Score}
+			}
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, 100).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&state).Error
+	})
+	return state, err
+}
+
+// Invalidate drops the user's materialized feeds, e.g. after they follow or
+// unfollow something, so that the next first page is rebuilt
+func Invalidate(db *gorm.DB, userID uint) error {
+	if config.FeedCacheTTLSeconds <= 0 {
+		return nil
+	}
+	return db.Where("user_id = ?", userID).Delete(&models.FeedCache{}).Error
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const pseudonymAttempts = 3
+
+var errAnonymousNotAllowed = errors.New("anonymous posting is not allowed in this section")
+
+// sectionAllowsAnonymous reports whether the section opted into anonymous posts
+func sectionAllowsAnonymous(slug string) bool {
+	var section models.Section
+	if err := storage.DB.Where("slug = ?", slug).First(&section).Error; err != nil {
+		return false
+	}
+	return section.AllowAnonymous
+}
+
+// threadPseudonym returns the user's stable pseudonym in a thread, assigning
+// the next free number on their first anonymous contribution. Concurrent
+// assignments collide on the unique (post_id, number) index and are retried.
+func threadPseudonym(tx *gorm.DB, postID, userID uint) (string, error) {
+	for attempt := 0; attempt < pseudonymAttempts; attempt++ {
+		var pseudonym models.ThreadPseudonym
+		err := tx.Where("post_id = ? AND user_id = ?", postID, userID).First(&pseudonym).Error
+		if err == nil {
+			return pseudonym.DisplayName(), nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", err
+		}
+
+		var maxNumber int
+		tx.Model(&models.ThreadPseudonym{}).Where("post_id = ?", postID).
+			Select("COALESCE(MAX(number), 0)").Scan(&maxNumber)
+		pseudonym = models.ThreadPseudonym{PostID: postID, UserID: userID, Number: maxNumber + 1}
+
+		// A savepoint keeps the surrounding transaction usable if the insert collides
+		err = tx.Transaction(func(inner *gorm.DB) error {
+			return inner.Create(&pseudonym).Error
+		})
+		if err == nil {
+			return pseudonym.DisplayName(), nil
+		}
+	}
+	return "", fmt.Errorf("failed to assign pseudonym in post %d", postID)
+}
+
+// revealAuthors exposes the real authors of anonymous posts to moderators
+func revealAuthors(posts []models.Post) {
+	for i := range posts {
+		posts[i].RevealAuthor = true
+		for j := range posts[i].Comments {
+			posts[i].Comments[j].RevealAuthor = true
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	maxAttachmentSize = 10 << 20  // 10 MB per file
+	userUploadQuota   = 200 << 20 // 200 MB of live attachments per user
+)
+
+// allowedAttachmentTypes lists the sniffed content types that can be uploaded
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// attachmentBlobKey derives a content-addressed key so identical uploads share a blob
+func attachmentBlobKey(hash string) string {
+	return fmt.Sprintf("attachments/%s/%s", hash[:2], hash)
+}
+
+// UploadAttachment stores an uploaded file: POST /api/attachments (multipart field "file")
+// The returned ID is passed as attachment_ids when creating a post or comment.
+func UploadAttachment(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+1<<20) // Allow for multipart overhead
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "A file is required (max 10 MB)", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxAttachmentSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read upload", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxAttachmentSize {
+		http.Error(w, "File is too large (max 10 MB)", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if len(data) == 0 {
+		http.Error(w, "File is empty", http.StatusBadRequest)
+		return
+	}
+
+	// Trust the bytes, not the client-provided Content-Type
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if !allowedAttachmentTypes[contentType] {
+		http.Error(w, "Only PNG, JPEG, GIF, WebP images and PDF files are allowed", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	var used int64
+	storage.DB.Model(&models.Attachment{}).Where("uploader_id = ?", userClaims.UserID).
+		Select("COALESCE(SUM(size), 0)").Scan(&used)
+	if used+int64(len(data)) > userUploadQuota {
+		http.Error(w, "Upload quota exceeded, delete some attachments first", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	attachment := models.Attachment{
+		UploaderID:  userClaims.UserID,
+		FileName:    sanitizeFileName(header.Filename),
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		SHA256:      hash,
+		BlobKey:     attachmentBlobKey(hash),
+	}
+
+	// Put is a no-op when the same content was uploaded before
+	if err := storage.Blobs.Put(attachment.BlobKey, bytes.NewReader(data)); err != nil {
+		log.Println("Blob store error:", err)
+		http.Error(w, "Failed to store file", http.StatusInternalServerError)
+		return
+	}
+	if strings.HasPrefix(contentType, "image/") {
+		if thumb, err := makeThumbnail(data); err == nil {
+			thumbKey := attachment.BlobKey + ".thumb.jpg"
+			if err := storage.Blobs.Put(thumbKey, bytes.NewReader(thumb)); err == nil {
+				attachment.ThumbnailKey = thumbKey
+			}
+		}
+	}
+
+	if err := storage.DB.Create(&attachment).Error; err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	attachment.HasThumbnail = attachment.ThumbnailKey != ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// GetAttachment serves the file: GET /api/attachments/{id}
+// Only viewers who can see the post or comment it belongs to get it.
+func GetAttachment(w http.ResponseWriter, r *http.Request) {
+	serveAttachment(w, r, false)
+}
+
+// GetAttachmentThumbnail serves the image preview: GET /api/attachments/{id}/thumbnail
+func GetAttachmentThumbnail(w http.ResponseWriter, r *http.Request) {
+	serveAttachment(w, r, true)
+}
+
+func serveAttachment(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	attachmentID, err := pathID(r, "/api/attachments/")
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	var attachment models.Attachment
+	if err := storage.DB.First(&attachment, attachmentID).Error; err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+	if !canSeeAttachment(visibility.FromRequest(r), &attachment) {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	key, contentType := attachment.BlobKey, attachment.ContentType
+	if thumbnail {
+		if attachment.ThumbnailKey == "" {
+			http.Error(w, "Attachment has no thumbnail", http.StatusNotFound)
+			return
+		}
+		key, contentType = attachment.ThumbnailKey, "image/jpeg"
+	}
+
+	blob, err := storage.Blobs.Open(key)
+	if err != nil {
+		log.Println("Blob store error:", err)
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+	defer blob.Close()
+
+	disposition := "inline"
+	if !strings.HasPrefix(contentType, "image/") {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": attachment.FileName}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// Blobs are content-addressed, but who may see them can change when
+	// their post is hidden or deleted, so shared caches must not keep them
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	io.Copy(w, blob)
+}
+
+// canSeeAttachment reports whether the viewer may download the attachment:
+// uploaders always can, others only while they can see the post or comment
+// it is attached to. Unlinked uploads are private to their uploader.
+func canSeeAttachment(viewer visibility.Viewer, attachment *models.Attachment) bool {
+	if viewer.UserID != 0 && viewer.UserID == attachment.UploaderID {
+		return true
+	}
+	postID := attachment.PostID
+	if attachment.CommentID != nil {
+		var comment models.Comment
+		if err := storage.DB.Scopes(viewer.Comments).First(&comment, *attachment.CommentID).Error; err != nil {
+			return false
+		}
+		postID = &comment.PostID
+	}
+	if postID == nil {
+		return false
+	}
+	var post models.Post
+	if err := storage.DB.First(&post, *postID).Error; err != nil {
+		return false
+	}
+	return viewer.CanSeePost(storage.DB, &post)
+}
+
+// DeleteAttachment removes one of the current user's attachments: DELETE /api/attachments/{id}
+// The blob itself is removed by the garbage collector once nothing references it.
+func DeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	attachmentID, err := pathID(r, "/api/attachments/")
+	if err != nil {
+		http.Error(w, "Invalid attachment ID", http.StatusBadRequest)
+		return
+	}
+
+	result := storage.DB.Where("id = ? AND uploader_id = ?", attachmentID, userClaims.UserID).Delete(&models.Attachment{})
+	if result.Error != nil {
+		log.Println("DB Delete error:", result.Error)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// linkAttachments attaches the user's unlinked uploads to a new post or
+// comment. column is "post_id" or "comment_id".
+func linkAttachments(tx *gorm.DB, userID uint, ids []uint, column string, ownerID uint) error {
+	ids = uniqueIDs(ids)
+	if len(ids) == 0 {
+		return nil
+	}
+	result := tx.Model(&models.Attachment{}).
+		Where("id IN ? AND uploader_id = ? AND post_id IS NULL AND comment_id IS NULL", ids, userID).
+		Update(column, ownerID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		return errInvalidAttachments
+	}
+	return nil
+}
+
+var errInvalidAttachments = fmt.Errorf("attachment_ids must be your own unused uploads")
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := ids[:0:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// sanitizeFileName keeps only the base name and strips characters that could
+// break the Content-Disposition header
+func sanitizeFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == '"' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		return "upload"
+	}
+	if len(name) > 200 {
+		name = name[len(name)-200:]
+	}
+	return name
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"encoding/csv"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const maxAuditExportRows = 10000
+
+type RoleRequest struct {
+	Role string `json:"role"` // "user", "moderator" or "admin"
+}
+
+type AuditLogResponse struct {
+	Entries []models.AuditEntry `json:"entries"`
+	Total   int64               `json:"total"`
+	Page    int                 `json:"page"`
+	Limit   int                 `json:"limit"`
+}
+
+// snapshot serializes a record for the before/after columns of the audit log
+func snapshot(v interface{}) models.JSONSnapshot {
+	if v == nil {
+		return ""
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("Warning: failed to snapshot %T for audit: %v", v, err)
+		return ""
+	}
+	return models.JSONSnapshot(data)
+}
+
+// recordAudit appends an entry to the audit log. Pass the same tx as the action
+// itself so that the entry exists if and only if the action happened.
+func recordAudit(tx *gorm.DB, r *http.Request, actor *models.User, action, targetType string, targetID uint, before, after interface{}, note string) (*models.AuditEntry, error) {
+	entry := models.AuditEntry{
+		ActorID:       actor.ID,
+		ActorUsername: actor.Username,
+		Action:        action,
+		TargetType:    targetType,
+		TargetID:      targetID,
+		Before:        snapshot(before),
+		After:         snapshot(after),
+		RequestID:     middleware.GetRequestID(r),
+		Note:          note,
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// requireAdmin is requireModerator for admin-only endpoints
+func requireAdmin(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var user models.User
+	if err := storage.DB.First(&user, userClaims.UserID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	if user.Role != models.RoleAdmin {
+		http.Error(w, "Admin privileges required", http.StatusForbidden)
+		return nil, false
+	}
+	return &user, true
+}
+
+// GetAuditLog lists audit entries, newest first:
+// GET /api/admin/audit?actor_id=&action=&target_type=&target_id=&request_id=&from=&to=&format=json|csv
+// Timestamps use RFC 3339. CSV exports ignore pagination and return up to 10000 rows.
+func GetAuditLog(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	query := storage.DB.Model(&models.AuditEntry{})
+	if actorID, err := strconv.ParseUint(q.Get("actor_id"), 10, 32); err == nil {
+		query = query.Where("actor_id = ?", actorID)
+	}
+	if action := q.Get("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if targetType := q.Get("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	if targetID, err := strconv.ParseUint(q.Get("target_id"), 10, 32); err == nil {
+		query = query.Where("target_id = ?", targetID)
+	}
+	if requestID := q.Get("request_id"); requestID != "" {
+		query = query.Where("request_id = ?", requestID)
+	}
+	for param, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at < ?"} {
+		if value := q.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "Invalid "+param+" timestamp, use RFC 3339", http.StatusBadRequest)
+				return
+			}
+			query = query.Where(condition, t)
+		}
+	}
+	query = query.Order("created_at desc, id desc").Session(&gorm.Session{})
+
+	if q.Get("format") == "csv" {
+		var entries []models.AuditEntry
+		if err := query.Limit(maxAuditExportRows).Find(&entries).Error; err != nil {
+			log.Println("DB Query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		writeAuditCSV(w, entries)
+		return
+	}
+
+	page, limit := parsePagination(r)
+	response := AuditLogResponse{Page: page, Limit: limit}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err := query.Offset((page - 1) * limit).Limit(limit).Find(&response.Entries).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if q.Get("format") == "json" {
+		w.Header().Set("Content-Disposition", `attachment; filename="audit.json"`)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func writeAuditCSV(w http.ResponseWriter, entries []models.AuditEntry) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "actor_id", "actor_username", "action", "target_type", "target_id", "request_id", "note", "before", "after"})
+	for _, e := range entries {
+		out.Write([]string{
+			strconv.FormatUint(uint64(e.ID), 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatUint(uint64(e.ActorID), 10),
+			e.ActorUsername,
+			e.Action,
+			e.TargetType,
+			strconv.FormatUint(uint64(e.TargetID), 10),
+			e.RequestID,
+			e.Note,
+			string(e.Before),
+			string(e.After),
+		})
+	}
+	out.Flush()
+}
+
+// SetUserRole changes a user's role: PUT /api/admin/users/{id}/role (admins only)
+func SetUserRole(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := pathID(r, "/api/admin/users/")
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req RoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.Role != models.RoleUser && req.Role != models.RoleModerator && req.Role != models.RoleAdmin {
+		http.Error(w, "Role must be user, moderator or admin", http.StatusBadRequest)
+		return
+	}
+	if userID == admin.ID {
+		http.Error(w, "You cannot change your own role", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := storage.DB.First(&user, userID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	before := map[string]string{"role": user.Role}
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, admin, models.AuditUserRole, "user", user.ID, before, map[string]string{"role": req.Role}, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("👑 Admin %s changed role of %s to %s", admin.Username, user.Username, req.Role)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": user.ID, "username": user.Username, "role": user.Role})
+}
@@ -1,16 +1,17 @@
 package handlers
 
 import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/mail"
 	"WaterlooStar/backend/models"
 	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/webhook"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"net/smtp"
-	"os"
 	"regexp"
 	"time"
 
@@ -106,6 +107,7 @@ func Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("User created successfully: ID=%d, Username=%s", user.ID, user.Username)
+	webhook.UserRegistered(&user)
 
 	// Skip sending verification email since we're auto-verifying
 	// if err := sendVerificationEmail(user.Email, user.Username, token); err != nil {
@@ -261,18 +263,7 @@ func generateJWTToken(userID uint, username string) (string, error) {
 }
 
 func sendVerificationEmail(email, username, token string) error {
-	// Email configuration - you should use environment variables for these
-	smtpHost := "smtp.gmail.com"
-	smtpPort := "587"
-	senderEmail := os.Getenv("SMTP_EMAIL")
-	senderPassword := os.Getenv("SMTP_PASSWORD")
-
-	if senderEmail == "" || senderPassword == "" {
-		log.Println("SMTP credentials not configured, skipping email send")
-		return nil // Don't fail registration if email isn't configured
-	}
-
-	verificationURL := fmt.Sprintf("http://localhost:8080/api/auth/verify-email?token=%s", token)
+	verificationURL := fmt.Sprintf("%s/api/auth/verify-email?token=%s", config.APIURL, token)
 
 	subject := "Verify Your Email - Student Community Forum"
 	body := fmt.Sprintf(`
@@ -290,9 +281,7 @@ func sendVerificationEmail(email, username, token string) error {
 		</html>
 	`, username, verificationURL, verificationURL)
 
-	message := fmt.Sprintf("To: %s\r\nSubject: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s", email, subject, body)
-
-	auth := smtp.PlainAuth("", senderEmail, senderPassword, smtpHost)
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, senderEmail, []string{email}, []byte(message))
-	return err
+	// Sent directly: the user is waiting for it. Without SMTP credentials this
+	// logs and returns nil so registration does not fail.
+	return mail.Send(mail.Message{To: email, Subject: subject, HTML: body})
 }
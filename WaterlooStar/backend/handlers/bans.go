@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type BanRequest struct {
+	UserID        uint   `json:"user_id"`
+	Section       string `json:"section"` // Empty for a site-wide ban
+	Reason        string `json:"reason"`
+	DurationHours int    `json:"duration_hours"` // 0 for a permanent ban
+}
+
+// BanErrorResponse is returned with 403 when a banned user tries to write
+type BanErrorResponse struct {
+	Message   string     `json:"message"`
+	Reason    string     `json:"reason"`
+	Section   string     `json:"section,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at"` // null for permanent bans
+	Permanent bool       `json:"permanent"`
+}
+
+// activeBans scopes a bans query to bans in force at now. Expired bans lift
+// automatically because they simply stop matching.
+func activeBans(now time.Time) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("lifted_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", now)
+	}
+}
+
+// findActiveBan returns the ban that currently stops the user from writing in
+// section, preferring permanent and then longest-running bans
+func findActiveBan(userID uint, section string) (*models.Ban, error) {
+	var ban models.Ban
+	err := storage.DB.Scopes(activeBans(time.Now())).
+		Where("user_id = ? AND (section = '' OR section = ?)", userID, section).
+		Order("expires_at DESC NULLS FIRST").First(&ban).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// checkBan is the write check used by CreatePost, CreateComment, TogglePostLike
+// and the other write paths. It writes a 403 with the ban details and returns
+// false when the user is banned site-wide or from section.
+func checkBan(w http.ResponseWriter, userID uint, section string) bool {
+	ban, err := findActiveBan(userID, section)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true
+	}
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return false
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(BanErrorResponse{
+		Message:   banMessage(ban),
+		Reason:    ban.Reason,
+		Section:   ban.Section,
+		ExpiresAt: ban.ExpiresAt,
+		Permanent: ban.ExpiresAt == nil,
+	})
+	return false
+}
+
+// banMessage explains a ban to the banned user
+func banMessage(ban *models.Ban) string {
+	message := "You are banned from posting"
+	if ban.Section != "" {
+		message = fmt.Sprintf("You are banned from posting in %s", ban.Section)
+	}
+	if ban.ExpiresAt != nil {
+		message += " until " + ban.ExpiresAt.Format(time.RFC1123)
+	}
+	return message
+}
+
+// Reasons issueBan refuses a ban, see banErrorStatus
+var (
+	errBanSelf      = errors.New("you cannot ban yourself")
+	errBanModerator = errors.New("only admins can ban moderators")
+)
+
+// banErrorStatus is the HTTP status for an error from issueBan, or 0 when
+// the error is not one of its refusals
+func banErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errBanSelf):
+		return http.StatusBadRequest
+	case errors.Is(err, errBanModerator):
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// issueBan bans a user site-wide (empty section) or from one section.
+// durationHours of 0 makes the ban permanent. Moderators cannot ban
+// themselves, and only admins can ban moderators.
+func issueBan(tx *gorm.DB, moderator, target *models.User, section, reason string, durationHours int, now time.Time) (*models.Ban, error) {
+	if target.ID == moderator.ID {
+		return nil, errBanSelf
+	}
+	if target.IsModerator() && moderator.Role != models.RoleAdmin {
+		return nil, errBanModerator
+	}
+
+	ban := models.Ban{
+		UserID:      target.ID,
+		Section:     section,
+		Reason:      reason,
+		ModeratorID: moderator.ID,
+	}
+	if durationHours > 0 {
+		expiresAt := now.Add(time.Duration(durationHours) * time.Hour)
+		ban.ExpiresAt = &expiresAt
+	}
+	if err := tx.Create(&ban).Error; err != nil {
+		return nil, err
+	}
+	return &ban, nil
+}
+
+// CreateBan bans a user: POST /api/mod/bans
+// Only admins can ban moderators.
+func CreateBan(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	var req BanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.DurationHours < 0 {
+		http.Error(w, "duration_hours cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	var target models.User
+	if err := storage.DB.First(&target, req.UserID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	var ban *models.Ban
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		ban, err = issueBan(tx, moderator, &target, req.Section, req.Reason, req.DurationHours, time.Now())
+		if err != nil {
+			return err
+		}
+		_, err = recordAudit(tx, r, moderator, models.AuditUserBan, "user", target.ID, nil, ban, req.Reason)
+		return err
+	})
+	if status := banErrorStatus(err); status != 0 {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("⛔ Moderator %s banned %s (section=%q, expires=%v)", moderator.Username, target.Username, ban.Section, ban.ExpiresAt)
+	notifyModeration(target.ID, moderator.ID, nil, banMessage(ban)+". Reason: "+ban.Reason)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(ban)
+}
+
+// GetBans lists bans, newest first: GET /api/mod/bans?user_id=&active=true
+func GetBans(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok {
+		return
+	}
+
+	query := storage.DB.Model(&models.Ban{})
+	if userID, err := strconv.ParseUint(r.URL.Query().Get("user_id"), 10, 32); err == nil {
+		query = query.Where("user_id = ?", userID)
+	}
+	if r.URL.Query().Get("active") == "true" {
+		query = query.Scopes(activeBans(time.Now()))
+	}
+
+	page, limit := parsePagination(r)
+	var bans []models.Ban
+	if err := query.Order("created_at desc").Offset((page - 1) * limit).Limit(limit).Find(&bans).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bans)
+}
+
+// LiftBan ends a ban early: DELETE /api/mod/bans/{id}
+func LiftBan(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	banID, err := pathID(r, "/api/mod/bans/")
+	if err != nil {
+		http.Error(w, "Invalid ban ID", http.StatusBadRequest)
+		return
+	}
+
+	var ban models.Ban
+	if err := storage.DB.First(&ban, banID).Error; err != nil {
+		http.Error(w, "Ban not found", http.StatusNotFound)
+		return
+	}
+	now := time.Now()
+	if !ban.IsActiveAt(now) {
+		http.Error(w, "Ban is no longer active", http.StatusConflict)
+		return
+	}
+
+	before := ban
+	ban.LiftedAt = &now
+	ban.LiftedByID = &moderator.ID
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ban).Select("lifted_at", "lifted_by_id").Updates(&ban).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditBanLift, "ban", ban.ID, before, ban, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("✅ Moderator %s lifted ban %d on user %d", moderator.Username, ban.ID, ban.UserID)
+	notifyModeration(ban.UserID, moderator.ID, nil, "Your ban has been lifted")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ban)
+}
+
+// GetMyBans lists the current user's active bans so clients can explain why
+// writing is disabled: GET /api/me/bans
+func GetMyBans(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	bans := []models.Ban{}
+	err := storage.DB.Scopes(activeBans(time.Now())).Where("user_id = ?", userClaims.UserID).
+		Order("created_at desc").Find(&bans).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bans)
+}
+
+type ShadowbanRequest struct {
+	Shadowbanned bool `json:"shadowbanned"`
+}
+
+// ShadowbanResponse reports a user's shadowban state. IsShadowbanned is not
+// part of the public user JSON so that users cannot tell they are shadowbanned.
+type ShadowbanResponse struct {
+	UserID       uint   `json:"user_id"`
+	Username     string `json:"username"`
+	Shadowbanned bool   `json:"shadowbanned"`
+}
+
+// SetShadowban shadowbans or un-shadowbans a user: PUT /api/mod/users/{id}/shadowban
+// A shadowbanned user keeps seeing their own posts and comments, nobody else
+// does, see package visibility. Only admins can shadowban moderators.
+func SetShadowban(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := pathID(r, "/api/mod/users/")
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ShadowbanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if userID == moderator.ID {
+		http.Error(w, "You cannot shadowban yourself", http.StatusBadRequest)
+		return
+	}
+
+	var target models.User
+	if err := storage.DB.First(&target, userID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	if target.IsModerator() && moderator.Role != models.RoleAdmin {
+		http.Error(w, "Only admins can shadowban moderators", http.StatusForbidden)
+		return
+	}
+
+	before := map[string]bool{"shadowbanned": target.IsShadowbanned}
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&target).Update("is_shadowbanned", req.Shadowbanned).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditUserShadowban, "user", target.ID, before, map[string]bool{"shadowbanned": req.Shadowbanned}, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("👻 Moderator %s set shadowbanned=%v on %s", moderator.Username, req.Shadowbanned, target.Username)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ShadowbanResponse{UserID: target.ID, Username: target.Username, Shadowbanned: target.IsShadowbanned})
+}
+
+// GetShadowbans lists shadowbanned users: GET /api/mod/shadowbans
+func GetShadowbans(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok {
+		return
+	}
+
+	var users []models.User
+	if err := storage.DB.Where("is_shadowbanned = ?", true).Order("username asc").Find(&users).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]ShadowbanResponse, len(users))
+	for i, user := range users {
+		response[i] = ShadowbanResponse{UserID: user.ID, Username: user.Username, Shadowbanned: true}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
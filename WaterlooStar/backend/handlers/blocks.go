@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// RelatedUser is an entry in the current user's block or mute list
+type RelatedUser struct {
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// userRelation describes the blocks and mutes tables, which share their handlers
+type userRelation struct {
+	table  string
+	column string // Column holding the other user
+	prefix string // URL prefix before {user_id}
+	newRow func(userID, otherID uint) interface{}
+	added  func(userID, otherID uint) // Optional, runs after a new relation is stored
+}
+
+var (
+	blockRelation = userRelation{
+		table:  "user_blocks",
+		column: "blocked_id",
+		prefix: "/api/me/blocks/",
+		newRow: func(userID, otherID uint) interface{} { return &models.UserBlock{UserID: userID, BlockedID: otherID} },
+		added:  dropFollows,
+	}
+	muteRelation = userRelation{
+		table:  "user_mutes",
+		column: "muted_id",
+		prefix: "/api/me/mutes/",
+		newRow: func(userID, otherID uint) interface{} { return &models.UserMute{UserID: userID, MutedID: otherID} },
+	}
+)
+
+// dropFollows removes follows between two users in both directions once one
+// blocks the other
+func dropFollows(userID, otherID uint) {
+	err := storage.DB.Where("kind = ? AND ((user_id = ? AND followed_id = ?) OR (user_id = ? AND followed_id = ?))",
+		models.FollowUser, userID, otherID, otherID, userID).Delete(&models.Follow{}).Error
+	if err != nil {
+		log.Printf("Warning: failed to drop follows between users %d and %d: %v", userID, otherID, err)
+	}
+}
+
+func (rel userRelation) list(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	users := []RelatedUser{}
+	err := storage.DB.Table(rel.table).
+		Select("users.id AS user_id, users.username, "+rel.table+".created_at").
+		Joins("JOIN users ON users.id = "+rel.table+"."+rel.column+" AND users.deleted_at IS NULL").
+		Where(rel.table+".user_id = ?", userClaims.UserID).
+		Order(rel.table + ".created_at desc").Scan(&users).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(users)
+}
+
+func (rel userRelation) add(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	otherID, err := pathID(r, rel.prefix)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	if otherID == userClaims.UserID {
+		http.Error(w, "You cannot do this to yourself", http.StatusBadRequest)
+		return
+	}
+	var other models.User
+	if err := storage.DB.First(&other, otherID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	result := storage.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(rel.newRow(userClaims.UserID, other.ID))
+	if result.Error != nil {
+		log.Println("DB Insert error:", result.Error)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected > 0 && rel.added != nil {
+		rel.added(userClaims.UserID, other.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(RelatedUser{UserID: other.ID, Username: other.Username, CreatedAt: time.Now()})
+}
+
+func (rel userRelation) remove(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	otherID, err := pathID(r, rel.prefix)
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	err = storage.DB.Where("user_id = ? AND "+rel.column+" = ?", userClaims.UserID, otherID).
+		Delete(rel.newRow(0, 0)).Error
+	if err != nil {
+		log.Println("DB Delete error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMyBlocks lists the users the current user has blocked: GET /api/me/blocks
+func GetMyBlocks(w http.ResponseWriter, r *http.Request) { blockRelation.list(w, r) }
+
+// BlockUser blocks a user in both directions: PUT /api/me/blocks/{user_id}
+func BlockUser(w http.ResponseWriter, r *http.Request) { blockRelation.add(w, r) }
+
+// UnblockUser removes a block: DELETE /api/me/blocks/{user_id}
+func UnblockUser(w http.ResponseWriter, r *http.Request) { blockRelation.remove(w, r) }
+
+// GetMyMutes lists the users the current user has muted: GET /api/me/mutes
+func GetMyMutes(w http.ResponseWriter, r *http.Request) { muteRelation.list(w, r) }
+
+// MuteUser hides a user's content from the current user: PUT /api/me/mutes/{user_id}
+func MuteUser(w http.ResponseWriter, r *http.Request) { muteRelation.add(w, r) }
+
+// UnmuteUser removes a mute: DELETE /api/me/mutes/{user_id}
+func UnmuteUser(w http.ResponseWriter, r *http.Request) { muteRelation.remove(w, r) }
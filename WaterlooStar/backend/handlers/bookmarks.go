@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxFolderNameLength = 64
+
+type BookmarkRequest struct {
+	Folder string `json:"folder"`
+}
+
+type BookmarkListResponse struct {
+	Bookmarks []models.Bookmark `json:"bookmarks"`
+	Folders   []string          `json:"folders"`
+	Total     int64             `json:"total"`
+	Page      int               `json:"page"`
+	Limit     int               `json:"limit"`
+}
+
+// BookmarkPost saves a post for the current user: PUT /api/posts/{id}/bookmark
+// Saving an already bookmarked post moves it to the requested folder.
+func BookmarkPost(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := postIDFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	// The body is optional, an empty one bookmarks into the unsorted folder
+	var req BookmarkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	req.Folder = strings.TrimSpace(req.Folder)
+	if len(req.Folder) > maxFolderNameLength {
+		http.Error(w, "Folder name is too long", http.StatusBadRequest)
+		return
+	}
+
+	var post models.Post
+	if err := storage.DB.First(&post, postID).Error; err != nil || !visibility.FromRequest(r).CanSeePost(storage.DB, &post) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	bookmark := models.Bookmark{
+		UserID: userClaims.UserID,
+		PostID: postID,
+		Folder: req.Folder,
+	}
+	err = storage.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "post_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"folder", "updated_at"}),
+	}).Create(&bookmark).Error
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Failed to bookmark post", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bookmark)
+}
+
+// UnbookmarkPost removes a saved post: DELETE /api/posts/{id}/bookmark
+func UnbookmarkPost(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := postIDFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	if err := storage.DB.Where("user_id = ? AND post_id = ?", userClaims.UserID, postID).Delete(&models.Bookmark{}).Error; err != nil {
+		log.Println("DB Delete error:", err)
+		http.Error(w, "Failed to remove bookmark", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMyBookmarks lists the current user's bookmarks, newest first:
+// GET /api/me/bookmarks?folder=&page=&limit=
+func GetMyBookmarks(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	page, limit := parsePagination(r)
+	// Bookmarks of deleted posts are skipped by the soft-delete scope on the join
+	viewer := visibility.FromRequest(r)
+	visiblePostIDs := storage.DB.Model(&models.Post{}).Select("posts.id").Scopes(viewer.Posts)
+	query := storage.DB.Model(&models.Bookmark{}).InnerJoins("Post").
+		Where("bookmarks.user_id = ? AND bookmarks.post_id IN (?)", userClaims.UserID, visiblePostIDs)
+	if folder, ok := r.URL.Query()["folder"]; ok {
+		query = query.Where("bookmarks.folder = ?", strings.TrimSpace(folder[0]))
+	}
+	query = query.Session(&gorm.Session{})
+
+	response := BookmarkListResponse{Page: page, Limit: limit}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	err := query.Order("bookmarks.created_at desc").
+		Offset((page - 1) * limit).Limit(limit).Find(&response.Bookmarks).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	storage.DB.Model(&models.Bookmark{}).Where("user_id = ? AND folder <> ''", userClaims.UserID).
+		Distinct().Order("folder").Pluck("folder", &response.Folders)
+
+	posts := make([]models.Post, len(response.Bookmarks))
+	for i := range response.Bookmarks {
+		posts[i] = response.Bookmarks[i].Post
+	}
+	annotateViewerState(posts, userClaims.UserID)
+	viewer.AdjustLikeCounts(storage.DB, posts)
+	for i := range response.Bookmarks {
+		response.Bookmarks[i].Post = posts[i]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -1,13 +1,23 @@
 package handlers
 
 import (
+	"WaterlooStar/backend/mention"
+	"WaterlooStar/backend/middleware"
 	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/realtime"
+	"WaterlooStar/backend/render"
 	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/subscription"
+	"WaterlooStar/backend/visibility"
+	"WaterlooStar/backend/webhook"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+
+	"gorm.io/gorm"
 )
 
 func GetComments(w http.ResponseWriter, r *http.Request) {
@@ -26,12 +36,25 @@ func GetComments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	viewer := visibility.FromRequest(r)
+	var post models.Post
+	if err := storage.DB.First(&post, postID).Error; err != nil || !viewer.CanSeePost(storage.DB, &post) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
 	var comments []models.Comment
-	if err := storage.DB.Where("post_id = ?", uint(postID)).Order("created_at asc").Find(&comments).Error; err != nil {
+	query := storage.DB.Preload("Attachments").Preload("Mentions").Scopes(viewer.Comments).Where("post_id = ?", uint(postID))
+	if err := query.Order("created_at asc").Find(&comments).Error; err != nil {
 		log.Println("DB Query error:", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+
+	for i := range comments {
+		comments[i].IsOwn = viewer.UserID != 0 && comments[i].AuthorID == viewer.UserID
+		comments[i].RevealAuthor = viewer.IsModerator
+	}
 	json.NewEncoder(w).Encode(comments)
 }
 
@@ -56,19 +79,100 @@ func CreateComment(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid body", http.StatusBadRequest)
 		return
 	}
+	if !render.ValidFormat(comment.ContentFormat) {
+		http.Error(w, "content_format must be plain or markdown", http.StatusBadRequest)
+		return
+	}
+
+	var post models.Post
+	if err := storage.DB.First(&post, postID).Error; err != nil || !visibility.FromRequest(r).CanSeePost(storage.DB, &post) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+	if post.IsLocked {
+		http.Error(w, "Post is locked", http.StatusForbidden)
+		return
+	}
+	if userClaims, ok := middleware.GetUserFromContext(r); ok && !checkBan(w, userClaims.UserID, post.Section) {
+		return
+	}
 
 	comment.PostID = uint(postID)
+	comment.Mentions = nil // Parsed from the content on save
+
+	// Always attribute the comment to the authenticated user, ignore the body
+	var author models.User
+	if userClaims, ok := middleware.GetUserFromContext(r); ok {
+		if err := storage.DB.First(&author, userClaims.UserID).Error; err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		comment.AuthorID = userClaims.UserID
+		comment.Author = userClaims.Username
+	}
 
 	// Set default author if not provided
 	if comment.Author == "" {
 		comment.Author = "Anonymous"
 	}
+	if comment.IsAnonymous && !sectionAllowsAnonymous(post.Section) {
+		http.Error(w, errAnonymousNotAllowed.Error(), http.StatusBadRequest)
+		return
+	}
 
-	if err := storage.DB.Create(&comment).Error; err != nil {
+	result, ok := runContentPolicy(w, r, &author, models.ReportTargetComment, 0, post.Section, "", comment.Content)
+	if !ok {
+		return
+	}
+	comment.Content, comment.ContentHash = result.Content, result.Fingerprint
+	comment.IsHidden = result.Flagged
+
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		if comment.IsAnonymous {
+			pseudonym, err := threadPseudonym(tx, comment.PostID, comment.AuthorID)
+			if err != nil {
+				return err
+			}
+			comment.Author = pseudonym
+		}
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		mentions, err := mention.Save(tx, comment.PostID, &comment.ID, comment.AuthorID, comment.IsAnonymous, comment.Content)
+		if err != nil {
+			return err
+		}
+		comment.Mentions = mentions
+		if comment.AuthorID != 0 && subscription.AutoSubscribes(tx, comment.AuthorID) {
+			if err := subscription.Subscribe(tx, comment.AuthorID, comment.PostID); err != nil {
+				return err
+			}
+		}
+		if comment.IsHidden {
+			if err := queueForReview(tx, models.ReportTargetComment, comment.ID, comment.AuthorID, post.Section, result.Reasons); err != nil {
+				return err
+			}
+		}
+		return linkAttachments(tx, comment.AuthorID, comment.AttachmentIDs, "comment_id", comment.ID)
+	})
+	if errors.Is(err, errInvalidAttachments) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		log.Println("DB Insert error:", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+	storage.DB.Where("comment_id = ?", comment.ID).Find(&comment.Attachments)
+	comment.IsOwn = true
+	if !comment.IsHidden {
+		// Mentioned users hear about the comment once, as a mention
+		mentioned := mention.Notify(storage.DB, comment.Mentions, &post, &comment)
+		subscription.NotifyComment(storage.DB, &post, &comment, mentioned)
+	}
+	realtime.PublishComment(&comment)
+	webhook.CommentCreated(&post, &comment)
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(comment)
@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/mention"
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/realtime"
+	"WaterlooStar/backend/render"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/subscription"
+	"WaterlooStar/backend/webhook"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DraftRequest is used for draft autosave. Nil fields are left unchanged so the
+// editor can send only what changed.
+type DraftRequest struct {
+	Section       *string `json:"section"`
+	Title         *string `json:"title"`
+	Content       *string `json:"content"`
+	ContentFormat *string `json:"content_format"`
+	Tags          *string `json:"tags"`
+}
+
+type PublishRequest struct {
+	PublishAt *time.Time `json:"publish_at"` // Empty or in the past publishes immediately
+}
+
+type DraftListResponse struct {
+	Drafts []models.Post `json:"drafts"`
+	Total  int64         `json:"total"`
+	Page   int           `json:"page"`
+	Limit  int           `json:"limit"`
+}
+
+func (req DraftRequest) apply(post *models.Post) {
+	if req.Section != nil {
+		post.Section = *req.Section
+	}
+	if req.Title != nil {
+		post.Title = *req.Title
+	}
+	if req.Content != nil {
+		post.Content = *req.Content
+	}
+	if req.ContentFormat != nil {
+		post.ContentFormat = *req.ContentFormat
+	}
+	if req.Tags != nil {
+		post.Tags = *req.Tags
+	}
+}
+
+// loadOwnDraft resolves /api/me/drafts/{id} to a draft or scheduled post owned
+// by the current user. Published posts are no longer drafts.
+func loadOwnDraft(w http.ResponseWriter, r *http.Request, userID uint) (*models.Post, bool) {
+	draftID, err := pathID(r, "/api/me/drafts/")
+	if err != nil {
+		http.Error(w, "Invalid draft ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var post models.Post
+	err = storage.DB.Where("id = ? AND author_id = ? AND status IN ?", draftID, userID,
+		[]string{models.PostStatusDraft, models.PostStatusScheduled}).First(&post).Error
+	if err != nil {
+		http.Error(w, "Draft not found", http.StatusNotFound)
+		return nil, false
+	}
+	return &post, true
+}
+
+// GetMyDrafts lists the current user's drafts and scheduled posts, most
+// recently edited first: GET /api/me/drafts?status=&page=&limit=
+func GetMyDrafts(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	statuses := []string{models.PostStatusDraft, models.PostStatusScheduled}
+	if status := r.URL.Query().Get("status"); status == models.PostStatusDraft || status == models.PostStatusScheduled {
+		statuses = []string{status}
+	}
+
+	page, limit := parsePagination(r)
+	query := storage.DB.Model(&models.Post{}).Where("author_id = ? AND status IN ?", userClaims.UserID, statuses).
+		Session(&gorm.Session{})
+
+	response := DraftListResponse{Page: page, Limit: limit}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	err := query.Scopes(preloadMentions).Order("updated_at desc").Offset((page - 1) * limit).Limit(limit).Find(&response.Drafts).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// CreateDraft starts a new draft: POST /api/me/drafts
+// Unlike CreatePost, every field is optional.
+func CreateDraft(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req DraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if req.ContentFormat != nil && !render.ValidFormat(*req.ContentFormat) {
+		http.Error(w, "content_format must be plain or markdown", http.StatusBadRequest)
+		return
+	}
+
+	var user models.User
+	if err := storage.DB.First(&user, userClaims.UserID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	post := models.Post{
+		AuthorID: user.ID,
+		Author:   user.Username,
+		Status:   models.PostStatusDraft,
+	}
+	req.apply(&post)
+
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		mentions, err := mention.Save(tx, post.ID, nil, post.AuthorID, post.IsAnonymous, post.Content)
+		if err != nil {
+			return err
+		}
+		post.Mentions = mentions
+		return subscription.Subscribe(tx, post.AuthorID, post.ID)
+	})
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(post)
+}
+
+// AutosaveDraft saves editor changes to a draft: PUT /api/me/drafts/{id}
+// Scheduled posts can be edited too and stay scheduled.
+func AutosaveDraft(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	post, ok := loadOwnDraft(w, r, userClaims.UserID)
+	if !ok {
+		return
+	}
+
+	var req DraftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.ContentFormat != nil && !render.ValidFormat(*req.ContentFormat) {
+		http.Error(w, "content_format must be plain or markdown", http.StatusBadRequest)
+		return
+	}
+	req.apply(post)
+
+	// Scheduled posts go live without another publish step, so edits to them
+	// are checked now. Drafts are checked by PublishDraft.
+	var reviewReasons []string
+	if post.Status == models.PostStatusScheduled {
+		var author models.User
+		if err := storage.DB.First(&author, userClaims.UserID).Error; err != nil {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		result, ok := runContentPolicy(w, r, &author, models.ReportTargetPost, post.ID, post.Section, post.Title, post.Content)
+		if !ok {
+			return
+		}
+		post.Title, post.Content, post.ContentHash = result.Title, result.Content, result.Fingerprint
+		post.IsHidden = result.Flagged
+		reviewReasons = result.Reasons
+	}
+
+	// Only touch editable columns so a concurrent publish by the scheduler is not undone
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(post).Where("status = ?", post.Status).
+			Select("section", "title", "content", "content_format", "content_html", "content_hash", "is_hidden", "tags", "updated_at").
+			Updates(post)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyPublished
+		}
+		var err error
+		if post.Mentions, err = mention.Save(tx, post.ID, nil, post.AuthorID, post.IsAnonymous, post.Content); err != nil {
+			return err
+		}
+		if !post.IsHidden {
+			return nil
+		}
+		return queueForReview(tx, models.ReportTargetPost, post.ID, post.AuthorID, post.Section, reviewReasons)
+	})
+	if errors.Is(err, errAlreadyPublished) {
+		// The scheduler published it in the meantime, the edit was not saved
+		http.Error(w, "Post is already published", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// DeleteDraft discards a draft or cancels a scheduled post: DELETE /api/me/drafts/{id}
+func DeleteDraft(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	post, ok := loadOwnDraft(w, r, userClaims.UserID)
+	if !ok {
+		return
+	}
+
+	if err := storage.DB.Delete(post).Error; err != nil {
+		log.Println("DB Delete error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+var errAlreadyPublished = errors.New("post is already published")
+
+// PublishDraft publishes a draft now or schedules it: POST /api/me/drafts/{id}/publish
+// Sending an empty publish_at for a scheduled post publishes it immediately.
+func PublishDraft(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	post, ok := loadOwnDraft(w, r, userClaims.UserID)
+	if !ok {
+		return
+	}
+
+	var req PublishRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	if post.Section == "" || post.Title == "" {
+		http.Error(w, "Section and title are required to publish", http.StatusBadRequest)
+		return
+	}
+	if !checkBan(w, userClaims.UserID, post.Section) {
+		return
+	}
+
+	now := time.Now()
+	previousStatus := post.Status
+	post.Status = models.PostStatusPublished
+	post.PublishAt = req.PublishAt
+	if req.PublishAt != nil && req.PublishAt.After(now) {
+		post.Status = models.PostStatusScheduled
+	}
+	if err := applyPostStatus(post, now); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var author models.User
+	if err := storage.DB.First(&author, userClaims.UserID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	policyResult, ok := runContentPolicy(w, r, &author, models.ReportTargetPost, post.ID, post.Section, post.Title, post.Content)
+	if !ok {
+		return
+	}
+	post.Title, post.Content, post.ContentHash = policyResult.Title, policyResult.Content, policyResult.Fingerprint
+	post.IsHidden = policyResult.Flagged
+
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(post).Where("status = ?", previousStatus).
+			Select("status", "publish_at", "published_at", "updated_at", "title", "content", "content_html", "content_hash", "is_hidden").
+			Updates(post)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyPublished
+		}
+		mentions, err := mention.Save(tx, post.ID, nil, post.AuthorID, post.IsAnonymous, post.Content)
+		if err != nil {
+			return err
+		}
+		post.Mentions = mentions
+		if post.IsHidden {
+			return queueForReview(tx, models.ReportTargetPost, post.ID, post.AuthorID, post.Section, policyResult.Reasons)
+		}
+		return nil
+	})
+	if errors.Is(err, errAlreadyPublished) {
+		// The scheduler published it in the meantime
+		http.Error(w, "Post is already published", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if post.IsVisible() {
+		mention.Notify(storage.DB, post.Mentions, post, nil)
+		realtime.PublishPost(post)
+		webhook.PostCreated(post)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
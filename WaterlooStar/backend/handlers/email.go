@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/mail"
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+// EmailPreferenceRequest updates email preferences. Nil fields are unchanged.
+type EmailPreferenceRequest struct {
+	Replies *bool   `json:"replies"`
+	Digest  *string `json:"digest"` // "none", "daily" or "weekly"
+}
+
+// loadEmailPreference returns the user's preference, or the defaults when
+// they never saved one
+func loadEmailPreference(userID uint) (models.EmailPreference, error) {
+	pref := models.EmailPreference{UserID: userID, Digest: models.DigestNone}
+	err := storage.DB.Where("user_id = ?", userID).FirstOrInit(&pref).Error
+	return pref, err
+}
+
+// GetEmailPreferences returns what the current user gets by email:
+// GET /api/me/email-preferences
+func GetEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	pref, err := loadEmailPreference(userClaims.UserID)
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pref)
+}
+
+// UpdateEmailPreferences changes reply emails and the digest:
+// PUT /api/me/email-preferences with e.g. {"replies": true, "digest": "weekly"}
+// Digests list posts from what the user follows, see package digest.
+func UpdateEmailPreferences(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req EmailPreferenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.Digest != nil && !models.ValidDigest(*req.Digest) {
+		http.Error(w, "digest must be none, daily or weekly", http.StatusBadRequest)
+		return
+	}
+
+	pref, err := loadEmailPreference(userClaims.UserID)
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if req.Replies != nil {
+		pref.Replies = *req.Replies
+	}
+	if req.Digest != nil {
+		pref.Digest = *req.Digest
+	}
+
+	if err := storage.DB.Save(&pref).Error; err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pref)
+}
+
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe - Student Community Forum</title></head>
+<body style="font-family: Arial, sans-serif; max-width: 480px; margin: 40px auto;">
+	{{if .Done}}
+	<h2 style="color: #d4a574;">You are unsubscribed</h2>
+	<p>You will no longer get {{.What}}. You can turn them back on in your settings.</p>
+	{{else}}
+	<h2 style="color: #d4a574;">Unsubscribe</h2>
+	<p>Stop getting {{.What}}?</p>
+	<form method="POST">
+		<input type="hidden" name="List-Unsubscribe" value="One-Click">
+		<button type="submit" style="background: #d4a574; color: white; padding: 10px 20px; border: none; border-radius: 5px;">Unsubscribe</button>
+	</form>
+	{{end}}
+</body>
+</html>
+`))
+
+// Unsubscribe handles the signed unsubscribe links in emails:
+// /api/email/unsubscribe?token=
+// GET shows a confirmation page so that link scanners do not unsubscribe
+// anyone; POST unsubscribes right away, which is what mail clients do for
+// RFC 8058 one-click unsubscribe.
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userID, kind, err := mail.ParseUnsubscribeToken(r.URL.Query().Get("token"))
+	if err != nil {
+		http.Error(w, "Invalid unsubscribe link", http.StatusBadRequest)
+		return
+	}
+
+	what := "reply emails"
+	if kind == mail.UnsubscribeDigest {
+		what = "digest emails"
+	}
+	done := r.Method == http.MethodPost
+	if done {
+		updates := map[string]interface{}{"replies": false}
+		if kind == mail.UnsubscribeDigest {
+			updates = map[string]interface{}{"digest": models.DigestNone}
+		}
+		err := storage.DB.Model(&models.EmailPreference{}).Where("user_id = ?", userID).Updates(updates).Error
+		if err != nil {
+			log.Println("DB Update error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		log.Printf("📭 User %d unsubscribed from %s", userID, what)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	unsubscribePage.Execute(w, map[string]interface{}{"Done": done, "What": what})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/feed"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// FeedResponse is one page of the home feed
+type FeedResponse struct {
+	Posts      []models.Post `json:"posts"`
+	NextCursor string        `json:"next_cursor,omitempty"` // Empty on the last page
+}
+
+// GetFeed returns the current user's home feed: posts by the users they
+// follow and in the sections and tags they follow.
+// GET /api/feed?sort=new|top&cursor=&limit=
+func GetFeed(w http.ResponseWriter, r *http.Request) {
+	viewer := visibility.FromRequest(r)
+	if viewer.UserID == 0 {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = feed.SortNew
+	}
+	if !feed.ValidSort(sort) {
+		http.Error(w, "sort must be new or top", http.StatusBadRequest)
+		return
+	}
+	_, limit := parsePagination(r)
+
+	ids, next, err := feed.Page(storage.DB, viewer, sort, r.URL.Query().Get("cursor"), limit)
+	if errors.Is(err, feed.ErrInvalidCursor) {
+		http.Error(w, "Invalid cursor", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	response := FeedResponse{Posts: []models.Post{}, NextCursor: next}
+	if len(ids) > 0 {
+		var posts []models.Post
+		err := storage.DB.Preload("Attachments").Scopes(preloadPoll, preloadMentions, viewer.Posts).
+			Where("posts.id IN ?", ids).Find(&posts).Error
+		if err != nil {
+			log.Println("DB Query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		byID := make(map[uint]models.Post, len(posts))
+		for _, post := range posts {
+			byID[post.ID] = post
+		}
+		for _, id := range ids {
+			if post, ok := byID[id]; ok {
+				response.Posts = append(response.Posts, post)
+			}
+		}
+	}
+
+	annotateViewerState(response.Posts, viewer.UserID)
+	annotatePolls(response.Posts, viewer.UserID)
+	viewer.AdjustLikeCounts(storage.DB, response.Posts)
+	if viewer.IsModerator {
+		revealAuthors(response.Posts)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
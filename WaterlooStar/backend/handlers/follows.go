@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/feed"
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxTagLength bounds followed tags; posts store tags as free text
+const maxTagLength = 50
+
+// FollowList is everything the current user follows
+type FollowList struct {
+	Users    []RelatedUser   `json:"users"`
+	Sections []models.Follow `json:"sections"`
+	Tags     []models.Follow `json:"tags"`
+}
+
+// UserProfile is the public view of a user. Email and contact info stay private.
+type UserProfile struct {
+	ID             uint      `json:"id"`
+	Username       string    `json:"username"`
+	Name           string    `json:"name,omitempty"`
+	SchoolYear     string    `json:"school_year,omitempty"`
+	Major          string    `json:"major,omitempty"`
+	Bio            string    `json:"bio,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+	IsFollowing    bool      `json:"is_following"`
+}
+
+// normalizeTag lowercases and trims a tag the way the feed matches them
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// followFromPath parses /api/me/follows/{users|sections|tags}/{target} into
+// the follow of userID it names, checking that the target exists
+func followFromPath(r *http.Request, userID uint) (models.Follow, int, string) {
+	follow := models.Follow{UserID: userID}
+	kind, target, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/me/follows/"), "/")
+	target, err := url.PathUnescape(target)
+	if err != nil || target == "" {
+		return follow, http.StatusBadRequest, "Invalid follow target"
+	}
+
+	switch kind {
+	case "users":
+		follow.Kind = models.FollowUser
+		id, err := pathID(r, "/api/me/follows/users/")
+		if err != nil {
+			return follow, http.StatusBadRequest, "Invalid user ID"
+		}
+		follow.FollowedID = id
+		if r.Method == http.MethodDelete {
+			return follow, 0, ""
+		}
+		if id == userID {
+			return follow, http.StatusBadRequest, "You cannot follow yourself"
+		}
+		var user models.User
+		if err := storage.DB.Select("id").First(&user, id).Error; err != nil {
+			return follow, http.StatusNotFound, "User not found"
+		}
+		if visibility.Blocked(storage.DB, userID, id) {
+			return follow, http.StatusForbidden, "You cannot follow this user"
+		}
+	case "sections":
+		follow.Kind = models.FollowSection
+		follow.Name = target
+		if r.Method == http.MethodDelete {
+			return follow, 0, ""
+		}
+		var count int64
+		storage.DB.Model(&models.Section{}).Where("slug = ?", target).Count(&count)
+		if count == 0 {
+			return follow, http.StatusNotFound, "Section not found"
+		}
+	case "tags":
+		follow.Kind = models.FollowTag
+		follow.Name = normalizeTag(target)
+		if follow.Name == "" || strings.Contains(follow.Name, ",") || utf8.RuneCountInString(follow.Name) > maxTagLength {
+			return follow, http.StatusBadRequest, "Invalid tag"
+		}
+	default:
+		return follow, http.StatusNotFound, "Not found"
+	}
+	return follow, 0, ""
+}
+
+// Follow adds a user, section or tag to the current user's feed:
+// PUT /api/me/follows/users/{user_id}, /api/me/follows/sections/{slug} or
+// /api/me/follows/tags/{tag}. Following twice is not an error.
+func Follow(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	follow, status, message := followFromPath(r, userClaims.UserID)
+	if message != "" {
+		http.Error(w, message, status)
+		return
+	}
+	result := storage.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&follow)
+	if result.Error != nil {
+		log.Println("DB Insert error:", result.Error)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected > 0 {
+		if err := feed.Invalidate(storage.DB, userClaims.UserID); err != nil {
+			log.Printf("Warning: failed to invalidate feed of user %d: %v", userClaims.UserID, err)
+		}
+		if follow.Kind == models.FollowUser {
+			log.Printf("➕ User %d followed user %d", userClaims.UserID, follow.FollowedID)
+		} else {
+			log.Printf("➕ User %d followed %s %q", userClaims.UserID, follow.Kind, follow.Name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(follow)
+}
+
+// Unfollow removes a user, section or tag from the current user's feed:
+// DELETE on the same paths as Follow
+func Unfollow(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	follow, status, message := followFromPath(r, userClaims.UserID)
+	if message != "" {
+		http.Error(w, message, status)
+		return
+	}
+	result := storage.DB.Where("user_id = ? AND kind = ? AND followed_id = ? AND name = ?",
+		follow.UserID, follow.Kind, follow.FollowedID, follow.Name).Delete(&models.Follow{})
+	if result.Error != nil {
+		log.Println("DB Delete error:", result.Error)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected > 0 {
+		if err := feed.Invalidate(storage.DB, userClaims.UserID); err != nil {
+			log.Printf("Warning: failed to invalidate feed of user %d: %v", userClaims.UserID, err)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMyFollows lists the users, sections and tags the current user follows:
+// GET /api/me/follows
+func GetMyFollows(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	list := FollowList{Users: []RelatedUser{}, Sections: []models.Follow{}, Tags: []models.Follow{}}
+	err := storage.DB.Table("follows").
+		Select("users.id AS user_id, users.username, follows.created_at").
+		Joins("JOIN users ON users.id = follows.followed_id AND users.deleted_at IS NULL").
+		Where("follows.user_id = ? AND follows.kind = ?", userClaims.UserID, models.FollowUser).
+		Order("follows.created_at desc").Scan(&list.Users).Error
+	if err == nil {
+		err = storage.DB.Where("user_id = ? AND kind = ?", userClaims.UserID, models.FollowSection).
+			Order("name").Find(&list.Sections).Error
+	}
+	if err == nil {
+		err = storage.DB.Where("user_id = ? AND kind = ?", userClaims.UserID, models.FollowTag).
+			Order("name").Find(&list.Tags).Error
+	}
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
+
+// GetUserProfile returns a user's public profile with follower counts:
+// GET /api/users/{user_id}
+// Followers who are shadowbanned are not counted, except by themselves.
+func GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	userID, err := pathID(r, "/api/users/")
+	if err != nil {
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		return
+	}
+	viewer := visibility.FromRequest(r)
+
+	var user models.User
+	if err := storage.DB.First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+			return
+		}
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if viewer.UserID != 0 && viewer.UserID != user.ID && visibility.Blocked(storage.DB, viewer.UserID, user.ID) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+
+	profile := UserProfile{
+		ID:         user.ID,
+		Username:   user.Username,
+		Name:       user.Name,
+		SchoolYear: user.SchoolYear,
+		Major:      user.Major,
+		Bio:        user.Bio,
+		CreatedAt:  user.CreatedAt,
+	}
+	err = storage.DB.Table("follows").
+		Joins("JOIN users ON users.id = follows.user_id AND users.deleted_at IS NULL").
+		Where("follows.kind = ? AND follows.followed_id = ?", models.FollowUser, user.ID).
+		Where("NOT users.is_shadowbanned OR users.id = ?", viewer.UserID).
+		Count(&profile.FollowerCount).Error
+	if err == nil {
+		err = storage.DB.Table("follows").
+			Joins("JOIN users ON users.id = follows.followed_id AND users.deleted_at IS NULL").
+			Where("follows.kind = ? AND follows.user_id = ?", models.FollowUser, user.ID).
+			Count(&profile.FollowingCount).Error
+	}
+	if err == nil && viewer.UserID != 0 {
+		var count int64
+		err = storage.DB.Model(&models.Follow{}).
+			Where("user_id = ? AND kind = ? AND followed_id = ?", viewer.UserID, models.FollowUser, user.ID).
+			Count(&count).Error
+		profile.IsFollowing = count > 0
+	}
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
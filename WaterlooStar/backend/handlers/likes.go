@@ -3,7 +3,10 @@ package handlers
 import (
 	"WaterlooStar/backend/middleware"
 	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/notify"
+	"WaterlooStar/backend/realtime"
 	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -39,11 +42,19 @@ func TogglePostLike(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if post exists
+	viewer := visibility.FromRequest(r)
 	var post models.Post
-	if err := storage.DB.First(&post, postID).Error; err != nil {
+	if err := storage.DB.First(&post, postID).Error; err != nil || !viewer.CanSeePost(storage.DB, &post) {
 		http.Error(w, "Post not found", http.StatusNotFound)
 		return
 	}
+	if post.IsLocked {
+		http.Error(w, "Post is locked", http.StatusForbidden)
+		return
+	}
+	if !checkBan(w, userClaims.UserID, post.Section) {
+		return
+	}
 
 	// Check if user already liked this post
 	var existingLike models.PostLike
@@ -68,9 +79,20 @@ func TogglePostLike(w http.ResponseWriter, r *http.Request) {
 		storage.DB.Model(&post).Update("likes", post.Likes+1)
 		liked = true
 		newCount = post.Likes + 1
+
+		notify.Send(storage.DB, notify.Event{
+			UserID:    post.AuthorID,
+			Type:      models.NotifyLike,
+			ActorID:   userClaims.UserID,
+			ActorName: userClaims.Username,
+			PostID:    &post.ID,
+			Subject:   post.Title,
+			Aggregate: true,
+		})
 	} else {
-		// User already liked this post, remove the like
-		if err := storage.DB.Delete(&existingLike).Error; err != nil {
+		// User already liked this post, remove the like. Only likes deleted
+		// along with their post are kept for restoring.
+		if err := storage.DB.Unscoped().Delete(&existingLike).Error; err != nil {
 			http.Error(w, "Failed to unlike post", http.StatusInternalServerError)
 			return
 		}
@@ -83,8 +105,14 @@ func TogglePostLike(w http.ResponseWriter, r *http.Request) {
 			newCount = 0
 		}
 		liked = false
+		notify.RecountLikes(storage.DB, post.AuthorID, post.ID)
 	}
 
+	// Likes by shadowbanned users do not count for anyone else
+	adjusted := []models.Post{{ID: post.ID, Likes: newCount}}
+	viewer.AdjustLikeCounts(storage.DB, adjusted)
+	realtime.PublishLikes(post.ID, newCount, userClaims.UserID)
+
 	response := LikeResponse{
 		Message: func() string {
 			if liked {
@@ -93,7 +121,7 @@ func TogglePostLike(w http.ResponseWriter, r *http.Request) {
 			return "Post unliked successfully"
 		}(),
 		Liked: liked,
-		Count: newCount,
+		Count: adjusted[0].Likes,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -116,8 +144,9 @@ func GetPostLikes(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get post
+	viewer := visibility.FromRequest(r)
 	var post models.Post
-	if err := storage.DB.First(&post, postID).Error; err != nil {
+	if err := storage.DB.First(&post, postID).Error; err != nil || !viewer.CanSeePost(storage.DB, &post) {
 		http.Error(w, "Post not found", http.StatusNotFound)
 		return
 	}
@@ -130,11 +159,13 @@ func GetPostLikes(w http.ResponseWriter, r *http.Request) {
 		err = storage.DB.Where("user_id = ? AND post_id = ?", userClaims.UserID, postID).First(&existingLike).Error
 		liked = (err == nil)
 	}
+	posts := []models.Post{post}
+	viewer.AdjustLikeCounts(storage.DB, posts)
 
 	response := LikeResponse{
 		Message: "Like status retrieved",
 		Liked:   liked,
-		Count:   post.Likes,
+		Count:   posts[0].Likes,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
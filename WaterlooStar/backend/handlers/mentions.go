@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/notify"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MentionItem is one place where the current user was mentioned
+type MentionItem struct {
+	PostID    uint      `json:"post_id"`
+	CommentID *uint     `json:"comment_id,omitempty"` // Nil when mentioned in the post itself
+	PostTitle string    `json:"post_title"`
+	Author    string    `json:"author"` // Username, or pseudonym when anonymous
+	Excerpt   string    `json:"excerpt"`
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	Content   string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type MentionListResponse struct {
+	Mentions []MentionItem `json:"mentions"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	Limit    int           `json:"limit"`
+}
+
+// preloadMentions loads the mention spans of posts and of their preloaded
+// comments. Post.Mentions only holds the mentions in the post itself.
+func preloadMentions(db *gorm.DB) *gorm.DB {
+	return db.Preload("Mentions", "comment_id IS NULL")
+}
+
+// GetMyMentions lists where the current user was mentioned, newest first:
+// GET /api/me/mentions?page=&limit=
+// Mentions in content the user cannot see, such as posts that were deleted
+// or content by blocked users, are left out.
+func GetMyMentions(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	viewer := visibility.FromRequest(r)
+	visiblePostIDs := storage.DB.Model(&models.Post{}).Select("posts.id").Scopes(viewer.Posts)
+	visibleCommentIDs := storage.DB.Model(&models.Comment{}).Select("comments.id").Scopes(viewer.Comments)
+	query := storage.DB.Table("mentions").
+		Joins("JOIN posts ON posts.id = mentions.post_id").
+		Joins("LEFT JOIN comments ON comments.id = mentions.comment_id").
+		Where("mentions.user_id = ? AND mentions.post_id IN (?)", userClaims.UserID, visiblePostIDs).
+		Where("mentions.comment_id IS NULL OR mentions.comment_id IN (?)", visibleCommentIDs).
+		Session(&gorm.Session{})
+
+	page, limit := parsePagination(r)
+	response := MentionListResponse{Page: page, Limit: limit, Mentions: []MentionItem{}}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	err := query.Select("mentions.post_id, mentions.comment_id, mentions.span_start AS start, mentions.span_end AS \"end\", " +
+		"mentions.created_at, posts.title AS post_title, " +
+		"COALESCE(comments.author, posts.author) AS author, COALESCE(comments.content, posts.content) AS content").
+		Order("mentions.created_at desc, mentions.id desc").
+		Offset((page - 1) * limit).Limit(limit).Scan(&response.Mentions).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range response.Mentions {
+		response.Mentions[i].Excerpt = notify.Excerpt(response.Mentions[i].Content, 140)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
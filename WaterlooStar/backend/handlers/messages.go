@@ -0,0 +1,593 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/realtime"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	maxConversationMembers = 10 // Including the creator
+	maxConversationTitle   = 100
+	maxMessageLength       = 5000
+)
+
+// ConversationRequest starts a conversation with the given users. Content is
+// an optional first message.
+type ConversationRequest struct {
+	UserIDs []uint `json:"user_ids"` // The other members
+	Title   string `json:"title"`    // Groups only
+	Content string `json:"content"`
+}
+
+type MessageRequest struct {
+	Content string `json:"content"`
+}
+
+// ReadRequest moves the read receipt to MessageID, or to the newest message
+// when it is 0
+type ReadRequest struct {
+	MessageID uint `json:"message_id"`
+}
+
+// ConversationSummary is a conversation as listed for one member
+type ConversationSummary struct {
+	models.Conversation
+	LastMessage *models.Message `json:"last_message,omitempty"`
+	UnreadCount int64           `json:"unread_count"`
+	IsMuted     bool            `json:"is_muted"`
+}
+
+type ConversationListResponse struct {
+	Conversations []ConversationSummary `json:"conversations"`
+	Unread        int64                 `json:"unread"` // Unread messages in conversations that are not muted
+	Total         int64                 `json:"total"`
+	Page          int                   `json:"page"`
+	Limit         int                   `json:"limit"`
+}
+
+type MessageListResponse struct {
+	Messages     []models.Message                 `json:"messages"`     // Newest first
+	Participants []models.ConversationParticipant `json:"participants"` // With read receipts
+	NextBefore   uint                             `json:"next_before,omitempty"`
+}
+
+// cleanMessage trims a message and checks its length
+func cleanMessage(content string) (string, bool) {
+	content = strings.TrimSpace(content)
+	return content, content != "" && utf8.RuneCountInString(content) <= maxMessageLength
+}
+
+// loadMembership returns the current user's membership of the conversation
+// in /api/conversations/{id}/..., answering 404 for conversations they are
+// not part of
+func loadMembership(w http.ResponseWriter, r *http.Request, userID uint) (*models.ConversationParticipant, bool) {
+	conversationID, err := pathID(r, "/api/conversations/")
+	if err != nil {
+		http.Error(w, "Invalid conversation ID", http.StatusBadRequest)
+		return nil, false
+	}
+	var member models.ConversationParticipant
+	err = storage.DB.Where("conversation_id = ? AND user_id = ?", conversationID, userID).Take(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &member, true
+}
+
+// loadParticipants loads the members of the conversations with their usernames
+func loadParticipants(conversationIDs []uint) (map[uint][]models.ConversationParticipant, error) {
+	var participants []models.ConversationParticipant
+	if err := storage.DB.Where("conversation_id IN ?", conversationIDs).Order("id").Find(&participants).Error; err != nil {
+		return nil, err
+	}
+	userIDs := make([]uint, len(participants))
+	for i, p := range participants {
+		userIDs[i] = p.UserID
+	}
+	var users []models.User
+	if err := storage.DB.Unscoped().Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	byConversation := make(map[uint][]models.ConversationParticipant, len(conversationIDs))
+	for _, p := range participants {
+		p.Username = usernames[p.UserID]
+		byConversation[p.ConversationID] = append(byConversation[p.ConversationID], p)
+	}
+	return byConversation, nil
+}
+
+// memberIDs returns the user IDs of the members, and those who muted it
+func memberIDs(participants []models.ConversationParticipant) ([]uint, map[uint]bool) {
+	ids := make([]uint, len(participants))
+	muted := make(map[uint]bool)
+	for i, p := range participants {
+		ids[i] = p.UserID
+		if p.IsMuted {
+			muted[p.UserID] = true
+		}
+	}
+	return ids, muted
+}
+
+// directBlocked reports whether the sender may not write in a one-to-one
+// conversation because either member blocked the other. In groups, messages
+// across blocks are stored but hidden from the members concerned.
+func directBlocked(conversation *models.Conversation, participants []models.ConversationParticipant, senderID uint) bool {
+	if conversation.IsGroup {
+		return false
+	}
+	for _, p := range participants {
+		if p.UserID != senderID && visibility.Blocked(storage.DB, senderID, p.UserID) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendMessage stores a message and moves the sender's read receipt to it
+func sendMessage(tx *gorm.DB, conversationID uint, sender *models.User, content string) (*models.Message, error) {
+	message := models.Message{ConversationID: conversationID, SenderID: sender.ID, Sender: sender.Username, Content: content}
+	if err := tx.Create(&message).Error; err != nil {
+		return nil, err
+	}
+	err := tx.Model(&models.Conversation{}).Where("id = ?", conversationID).
+		Updates(map[string]interface{}{"last_message_at": message.CreatedAt, "updated_at": message.CreatedAt}).Error
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Model(&models.ConversationParticipant{}).Where("conversation_id = ? AND user_id = ?", conversationID, sender.ID).
+		Updates(map[string]interface{}{"last_read_message_id": message.ID, "last_read_at": message.CreatedAt}).Error
+	return &message, err
+}
+
+// CreateConversation starts a conversation, optionally with a first message:
+// POST /api/conversations with {"user_ids": [2], "content": "Is the textbook still available?"}
+// One user makes a one-to-one conversation, which is reused if it exists;
+// more make a group. Users who blocked the creator, or were blocked by
+// them, cannot be added.
+func CreateConversation(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req ConversationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	seen := map[uint]bool{userClaims.UserID: true}
+	var otherIDs []uint
+	for _, id := range req.UserIDs {
+		if !seen[id] {
+			seen[id] = true
+			otherIDs = append(otherIDs, id)
+		}
+	}
+	if len(otherIDs) == 0 {
+		http.Error(w, "user_ids must name at least one other user", http.StatusBadRequest)
+		return
+	}
+	if len(otherIDs)+1 > maxConversationMembers {
+		http.Error(w, "Too many members", http.StatusBadRequest)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if utf8.RuneCountInString(req.Title) > maxConversationTitle {
+		http.Error(w, "Title is too long", http.StatusBadRequest)
+		return
+	}
+	content, valid := cleanMessage(req.Content)
+	if req.Content != "" && !valid {
+		http.Error(w, "Message must be 1 to 5000 characters", http.StatusBadRequest)
+		return
+	}
+
+	var sender models.User
+	if err := storage.DB.First(&sender, userClaims.UserID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	var count int64
+	storage.DB.Model(&models.User{}).Where("id IN ?", otherIDs).Count(&count)
+	if int(count) != len(otherIDs) {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	for _, id := range otherIDs {
+		if visibility.Blocked(storage.DB, sender.ID, id) {
+			http.Error(w, "You cannot message this user", http.StatusForbidden)
+			return
+		}
+	}
+
+	conversation := models.Conversation{CreatorID: sender.ID, IsGroup: len(otherIDs) > 1}
+	if conversation.IsGroup {
+		conversation.Title = req.Title
+	} else {
+		key := models.DirectKey(sender.ID, otherIDs[0])
+		conversation.DirectKey = &key
+	}
+
+	var message *models.Message
+	created := false
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&conversation)
+		if result.Error != nil {
+			return result.Error
+		}
+		created = result.RowsAffected > 0
+		if !created {
+			// The pair already has a conversation
+			if err := tx.Where("direct_key = ?", *conversation.DirectKey).Take(&conversation).Error; err != nil {
+				return err
+			}
+		}
+
+		members := []models.ConversationParticipant{{ConversationID: conversation.ID, UserID: sender.ID}}
+		for _, id := range otherIDs {
+			members = append(members, models.ConversationParticipant{ConversationID: conversation.ID, UserID: id})
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&members).Error; err != nil {
+			return err
+		}
+		if content != "" {
+			var err error
+			message, err = sendMessage(tx, conversation.ID, &sender, content)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	participants, err := loadParticipants([]uint{conversation.ID})
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	conversation.Participants = participants[conversation.ID]
+	if message != nil {
+		ids, muted := memberIDs(conversation.Participants)
+		realtime.PublishMessage(message, ids, muted)
+	}
+	if created {
+		log.Printf("💬 User %d started conversation %d with %d members", sender.ID, conversation.ID, len(otherIDs)+1)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(ConversationSummary{Conversation: conversation, LastMessage: message})
+}
+
+// GetConversations lists the current user's conversations, most recently
+// active first, with unread counts: GET /api/conversations?page=&limit=
+// Messages the user may not see, e.g. across blocks, are not counted.
+func GetConversations(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	viewer := visibility.Viewer{UserID: userClaims.UserID}
+	page, limit := parsePagination(r)
+	query := storage.DB.Model(&models.Conversation{}).
+		Joins("JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id AND conversation_participants.user_id = ?", viewer.UserID).
+		Session(&gorm.Session{})
+
+	response := ConversationListResponse{Page: page, Limit: limit, Conversations: []ConversationSummary{}}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	var conversations []models.Conversation
+	err := query.Order("conversations.last_message_at DESC NULLS LAST, conversations.id desc").
+		Offset((page - 1) * limit).Limit(limit).Find(&conversations).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var unread []struct {
+		ConversationID uint
+		IsMuted        bool
+		Count          int64
+	}
+	err = storage.DB.Model(&models.Message{}).Scopes(viewer.Messages).
+		Joins("JOIN conversation_participants ON conversation_participants.conversation_id = messages.conversation_id AND conversation_participants.user_id = ?", viewer.UserID).
+		Where("messages.id > conversation_participants.last_read_message_id AND messages.sender_id <> ?", viewer.UserID).
+		Select("messages.conversation_id, conversation_participants.is_muted, COUNT(*) AS count").
+		Group("messages.conversation_id, conversation_participants.is_muted").Scan(&unread).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	unreadCounts := make(map[uint]int64, len(unread))
+	for _, u := range unread {
+		unreadCounts[u.ConversationID] = u.Count
+		if !u.IsMuted {
+			response.Unread += u.Count
+		}
+	}
+
+	if len(conversations) > 0 {
+		ids := make([]uint, len(conversations))
+		for i := range conversations {
+			ids[i] = conversations[i].ID
+		}
+		participants, err := loadParticipants(ids)
+		if err != nil {
+			log.Println("DB Query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		var lastMessages []models.Message
+		err = storage.DB.Scopes(viewer.Messages).Where("messages.conversation_id IN ?", ids).
+			Select("DISTINCT ON (messages.conversation_id) messages.*").
+			Order("messages.conversation_id, messages.id desc").Find(&lastMessages).Error
+		if err != nil {
+			log.Println("DB Query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		byConversation := make(map[uint]*models.Message, len(lastMessages))
+		for i := range lastMessages {
+			byConversation[lastMessages[i].ConversationID] = &lastMessages[i]
+		}
+
+		for _, conversation := range conversations {
+			conversation.Participants = participants[conversation.ID]
+			summary := ConversationSummary{
+				Conversation: conversation,
+				LastMessage:  byConversation[conversation.ID],
+				UnreadCount:  unreadCounts[conversation.ID],
+			}
+			for _, p := range conversation.Participants {
+				if p.UserID == viewer.UserID {
+					summary.IsMuted = p.IsMuted
+				}
+			}
+			response.Conversations = append(response.Conversations, summary)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// GetMessages returns a page of messages, newest first, with the members'
+// read receipts: GET /api/conversations/{id}/messages?before=&limit=
+// Pass next_before as ?before= for older messages.
+func GetMessages(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	member, ok := loadMembership(w, r, userClaims.UserID)
+	if !ok {
+		return
+	}
+	_, limit := parsePagination(r)
+
+	viewer := visibility.Viewer{UserID: userClaims.UserID}
+	query := storage.DB.Scopes(viewer.Messages).Where("messages.conversation_id = ?", member.ConversationID)
+	if before := r.URL.Query().Get("before"); before != "" {
+		beforeID, err := strconv.ParseUint(before, 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid before", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("messages.id < ?", beforeID)
+	}
+
+	response := MessageListResponse{Messages: []models.Message{}}
+	if err := query.Order("messages.id desc").Limit(limit + 1).Find(&response.Messages).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if len(response.Messages) > limit {
+		response.Messages = response.Messages[:limit]
+		response.NextBefore = response.Messages[limit-1].ID
+	}
+	participants, err := loadParticipants([]uint{member.ConversationID})
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	response.Participants = participants[member.ConversationID]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// SendMessage posts a message to a conversation:
+// POST /api/conversations/{id}/messages with {"content": "..."}
+// Members who are online get it on their notifications stream.
+func SendMessage(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	member, ok := loadMembership(w, r, userClaims.UserID)
+	if !ok {
+		return
+	}
+
+	var req MessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	content, valid := cleanMessage(req.Content)
+	if !valid {
+		http.Error(w, "Message must be 1 to 5000 characters", http.StatusBadRequest)
+		return
+	}
+
+	var sender models.User
+	if err := storage.DB.First(&sender, userClaims.UserID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return
+	}
+	var conversation models.Conversation
+	if err := storage.DB.First(&conversation, member.ConversationID).Error; err != nil {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	participants, err := loadParticipants([]uint{conversation.ID})
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if directBlocked(&conversation, participants[conversation.ID], sender.ID) {
+		http.Error(w, "You cannot message this user", http.StatusForbidden)
+		return
+	}
+
+	var message *models.Message
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		message, err = sendMessage(tx, conversation.ID, &sender, content)
+		return err
+	})
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	ids, muted := memberIDs(participants[conversation.ID])
+	realtime.PublishMessage(message, ids, muted)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(message)
+}
+
+// MarkConversationRead moves the current user's read receipt forward and
+// tells the other members: PUT /api/conversations/{id}/read
+// The body {"message_id": 42} is optional; without it everything is read.
+func MarkConversationRead(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	member, ok := loadMembership(w, r, userClaims.UserID)
+	if !ok {
+		return
+	}
+
+	var req ReadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	messages := storage.DB.Model(&models.Message{}).Where("conversation_id = ?", member.ConversationID)
+	if req.MessageID != 0 {
+		messages = messages.Where("id = ?", req.MessageID)
+	}
+	var lastID uint
+	if err := messages.Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if req.MessageID != 0 && lastID == 0 {
+		http.Error(w, "Message not found", http.StatusNotFound)
+		return
+	}
+
+	// Receipts only move forward, also when requests race
+	now := time.Now()
+	result := storage.DB.Model(&models.ConversationParticipant{}).
+		Where("id = ? AND last_read_message_id < ?", member.ID, lastID).
+		Updates(map[string]interface{}{"last_read_message_id": lastID, "last_read_at": now})
+	if result.Error != nil {
+		log.Println("DB Update error:", result.Error)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected > 0 {
+		participants, err := loadParticipants([]uint{member.ConversationID})
+		if err == nil {
+			ids, _ := memberIDs(participants[member.ConversationID])
+			realtime.PublishReadReceipt(realtime.ReadReceipt{
+				ConversationID:    member.ConversationID,
+				UserID:            userClaims.UserID,
+				LastReadMessageID: lastID,
+				ReadAt:            now,
+			}, ids)
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MuteConversation stops alerts for a conversation: PUT /api/conversations/{id}/mute
+// Messages still arrive, marked as muted, and are left out of the unread total.
+func MuteConversation(w http.ResponseWriter, r *http.Request) { setConversationMute(w, r, true) }
+
+// UnmuteConversation turns alerts back on: DELETE /api/conversations/{id}/mute
+func UnmuteConversation(w http.ResponseWriter, r *http.Request) { setConversationMute(w, r, false) }
+
+func setConversationMute(w http.ResponseWriter, r *http.Request, muted bool) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	member, ok := loadMembership(w, r, userClaims.UserID)
+	if !ok {
+		return
+	}
+
+	if err := storage.DB.Model(member).Update("is_muted", muted).Error; err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
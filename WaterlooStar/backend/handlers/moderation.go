@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type PinRequest struct {
+	Pinned bool   `json:"pinned"`
+	Scope  string `json:"scope"` // "global" or "section", defaults to "section"
+	Order  int    `json:"order"` // Lower values are listed first
+}
+
+type LockRequest struct {
+	Locked bool `json:"locked"`
+}
+
+type FeatureRequest struct {
+	Featured bool `json:"featured"`
+}
+
+// requireModerator loads the authenticated user and checks their role.
+// It writes the error response itself and returns false if the check fails.
+func requireModerator(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	var user models.User
+	if err := storage.DB.First(&user, userClaims.UserID).Error; err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !user.IsModerator() {
+		http.Error(w, "Moderator privileges required", http.StatusForbidden)
+		return nil, false
+	}
+	return &user, true
+}
+
+// loadPostForModeration resolves the post in /api/posts/{id}/... for a moderator action
+func loadPostForModeration(w http.ResponseWriter, r *http.Request) (*models.Post, bool) {
+	postID, err := postIDFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return nil, false
+	}
+
+	var post models.Post
+	if err := storage.DB.First(&post, postID).Error; err != nil {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return nil, false
+	}
+	post.RevealAuthor = true
+	return &post, true
+}
+
+// SetPostPin pins or unpins a post: PUT /api/posts/{id}/pin
+func SetPostPin(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+	post, ok := loadPostForModeration(w, r)
+	if !ok {
+		return
+	}
+
+	var req PinRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	updates := map[string]interface{}{"is_pinned": false, "pin_scope": "", "pin_order": 0}
+	if req.Pinned {
+		if req.Scope == "" {
+			req.Scope = models.PinScopeSection
+		}
+		if req.Scope != models.PinScopeGlobal && req.Scope != models.PinScopeSection {
+			http.Error(w, "Scope must be 'global' or 'section'", http.StatusBadRequest)
+			return
+		}
+		updates = map[string]interface{}{"is_pinned": true, "pin_scope": req.Scope, "pin_order": req.Order}
+	}
+
+	before := *post
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(post).Updates(updates).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditPostPin, "post", post.ID, before, post, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("📌 Moderator %s set pinned=%v on post %d", moderator.Username, req.Pinned, post.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// SetPostLock locks or unlocks a post: PUT /api/posts/{id}/lock
+func SetPostLock(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+	post, ok := loadPostForModeration(w, r)
+	if !ok {
+		return
+	}
+
+	var req LockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	before := *post
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(post).Update("is_locked", req.Locked).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditPostLock, "post", post.ID, before, post, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🔒 Moderator %s set locked=%v on post %d", moderator.Username, req.Locked, post.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
+
+// SetPostFeature features or unfeatures a post: PUT /api/posts/{id}/feature
+func SetPostFeature(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+	post, ok := loadPostForModeration(w, r)
+	if !ok {
+		return
+	}
+
+	var req FeatureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	before := *post
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(post).Update("is_featured", req.Featured).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditPostFeature, "post", post.ID, before, post, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("⭐ Moderator %s set featured=%v on post %d", moderator.Username, req.Featured, post.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(post)
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/notify"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationListResponse struct {
+	Notifications []models.Notification `json:"notifications"`
+	Unread        int64                 `json:"unread"`
+	Total         int64                 `json:"total"`
+	Page          int                   `json:"page"`
+	Limit         int                   `json:"limit"`
+}
+
+// notifyModeration tells a user about a moderator action. Moderators are not
+// named to the user.
+func notifyModeration(userID, moderatorID uint, postID *uint, message string) {
+	notify.Send(storage.DB, notify.Event{
+		UserID:    userID,
+		Type:      models.NotifyModeration,
+		ActorID:   moderatorID,
+		ActorName: "A moderator",
+		PostID:    postID,
+		Body:      message,
+	})
+}
+
+// GetMyNotifications lists the current user's notifications, most recent
+// activity first: GET /api/me/notifications?unread=true&page=&limit=
+func GetMyNotifications(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	viewer := visibility.Viewer{UserID: userClaims.UserID}
+	base := storage.DB.Model(&models.Notification{}).Scopes(viewer.Notifications).Session(&gorm.Session{})
+	query := base
+	if r.URL.Query().Get("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	page, limit := parsePagination(r)
+	response := NotificationListResponse{Notifications: []models.Notification{}, Page: page, Limit: limit}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	base.Where("read_at IS NULL").Count(&response.Unread)
+
+	err := query.Order("updated_at desc").Offset((page - 1) * limit).Limit(limit).Find(&response.Notifications).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// MarkNotificationRead marks one notification read: POST /api/me/notifications/{id}/read
+func MarkNotificationRead(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	notificationID, err := pathID(r, "/api/me/notifications/")
+	if err != nil {
+		http.Error(w, "Invalid notification ID", http.StatusBadRequest)
+		return
+	}
+	result := storage.DB.Model(&models.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", notificationID, userClaims.UserID).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		log.Println("DB Update error:", result.Error)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MarkAllNotificationsRead marks every notification read: POST /api/me/notifications/read-all
+func MarkAllNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	err := storage.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userClaims.UserID).
+		Update("read_at", time.Now()).Error
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetNotificationPreferences returns which notification types are on:
+// GET /api/me/notification-preferences
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notificationPreferences(userClaims.UserID))
+}
+
+// UpdateNotificationPreferences switches notification types on or off:
+// PUT /api/me/notification-preferences with e.g. {"like": false}
+// Types left out are unchanged. "auto_subscribe" subscribes the user to the
+// posts they comment on.
+func UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	preferences := make([]models.NotificationPreference, 0, len(req))
+	for notificationType, enabled := range req {
+		if !validNotificationType(notificationType) {
+			http.Error(w, "Unknown notification type: "+notificationType, http.StatusBadRequest)
+			return
+		}
+		preferences = append(preferences, models.NotificationPreference{UserID: userClaims.UserID, Type: notificationType, Enabled: enabled})
+	}
+
+	if len(preferences) > 0 {
+		err := storage.DB.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+		}).Create(&preferences).Error
+		if err != nil {
+			log.Println("DB Insert error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notificationPreferences(userClaims.UserID))
+}
+
+// notificationPreferences maps every configurable type to whether it is on,
+// along with AutoSubscribe
+func notificationPreferences(userID uint) map[string]bool {
+	preferences := make(map[string]bool, len(models.NotificationTypes)+1)
+	for _, notificationType := range models.NotificationTypes {
+		preferences[notificationType] = true
+	}
+	preferences[models.AutoSubscribe] = false
+	var stored []models.NotificationPreference
+	storage.DB.Where("user_id = ?", userID).Find(&stored)
+	for _, p := range stored {
+		if _, ok := preferences[p.Type]; ok {
+			preferences[p.Type] = p.Enabled
+		}
+	}
+	return preferences
+}
+
+func validNotificationType(notificationType string) bool {
+	if notificationType == models.AutoSubscribe {
+		return true
+	}
+	for _, t := range models.NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/policy"
+	"WaterlooStar/backend/storage"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ContentFilterRequest struct {
+	Section string `json:"section"` // Empty to apply everywhere
+	Pattern string `json:"pattern"`
+	IsRegex bool   `json:"is_regex"`
+	Action  string `json:"action"` // "block", "mask" or "flag"
+}
+
+// PolicyErrorResponse is returned with 422 when the content policy blocks a submission
+type PolicyErrorResponse struct {
+	Message string   `json:"message"`
+	Reasons []string `json:"reasons"`
+}
+
+// runContentPolicy checks a post or comment by author. id is the post being
+// rechecked, so that it does not count as its own duplicate, or 0 for new
+// content. It writes a 422 with the reasons and returns false when the
+// content is blocked. Callers store result.Title and result.Content, which
+// may have words masked, and pass flagged content to queueForReview.
+func runContentPolicy(w http.ResponseWriter, r *http.Request, author *models.User, kind string, id uint, section, title, content string) (*policy.Result, bool) {
+	now := time.Now()
+	result, err := policy.Check(r.Context(), storage.DB, policy.Input{
+		Kind:       kind,
+		ID:         id,
+		Section:    section,
+		AuthorID:   author.ID,
+		AccountAge: now.Sub(author.CreatedAt),
+		Title:      title,
+		Content:    content,
+	}, now)
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	if result.Blocked {
+		log.Printf("🚫 Content policy blocked %s by %s: %s", kind, author.Username, strings.Join(result.Reasons, "; "))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(PolicyErrorResponse{
+			Message: "Your " + kind + " was rejected by the content policy",
+			Reasons: result.Reasons,
+		})
+		return nil, false
+	}
+	return result, true
+}
+
+// queueForReview files an automated report so that content hidden by the
+// content policy shows up in the moderation queue. Dismissing the report
+// publishes the content. Content flagged again after an edit reopens its report.
+func queueForReview(tx *gorm.DB, targetType string, targetID, authorID uint, section string, reasons []string) error {
+	report := models.Report{
+		TargetType:   targetType,
+		TargetID:     targetID,
+		TargetUserID: authorID,
+		Section:      section,
+		Reason:       models.ReportReasonAutomated,
+		Details:      strings.Join(reasons, "; "),
+		Status:       models.ReportStatusOpen,
+	}
+	err := tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "reporter_id"}, {Name: "target_type"}, {Name: "target_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"details":        report.Details,
+			"section":        report.Section,
+			"status":         models.ReportStatusOpen,
+			"resolved_by_id": nil,
+			"resolved_at":    nil,
+			"resolution":     "",
+			"updated_at":     time.Now(),
+		}),
+	}).Create(&report).Error
+	if err != nil {
+		return err
+	}
+	log.Printf("🚩 Content policy queued %s %d for review: %s", targetType, targetID, report.Details)
+	return nil
+}
+
+// GetContentFilters lists content filters: GET /api/mod/filters?section=
+func GetContentFilters(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok {
+		return
+	}
+
+	query := storage.DB.Order("id asc")
+	if section := r.URL.Query().Get("section"); section != "" {
+		query = query.Where("section = ?", section)
+	}
+	filters := []models.ContentFilter{}
+	if err := query.Find(&filters).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filters)
+}
+
+// CreateContentFilter adds a banned word or pattern: POST /api/mod/filters
+func CreateContentFilter(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	var req ContentFilterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	filter := models.ContentFilter{
+		Section:     strings.TrimSpace(req.Section),
+		Pattern:     strings.TrimSpace(req.Pattern),
+		IsRegex:     req.IsRegex,
+		Action:      req.Action,
+		CreatedByID: moderator.ID,
+	}
+	if filter.Pattern == "" {
+		http.Error(w, "Pattern is required", http.StatusBadRequest)
+		return
+	}
+	if filter.Action != models.FilterActionBlock && filter.Action != models.FilterActionMask && filter.Action != models.FilterActionFlag {
+		http.Error(w, "Action must be block, mask or flag", http.StatusBadRequest)
+		return
+	}
+	if _, err := policy.CompileFilter(filter); err != nil {
+		http.Error(w, "Invalid regular expression: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.Section != "" {
+		var count int64
+		storage.DB.Model(&models.Section{}).Where("slug = ?", filter.Section).Count(&count)
+		if count == 0 {
+			http.Error(w, "Section not found", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&filter).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditFilterCreate, "filter", filter.ID, nil, filter, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(filter)
+}
+
+// DeleteContentFilter removes a filter: DELETE /api/mod/filters/{id}
+func DeleteContentFilter(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	filterID, err := pathID(r, "/api/mod/filters/")
+	if err != nil {
+		http.Error(w, "Invalid filter ID", http.StatusBadRequest)
+		return
+	}
+	var filter models.ContentFilter
+	if err := storage.DB.First(&filter, filterID).Error; err != nil {
+		http.Error(w, "Filter not found", http.StatusNotFound)
+		return
+	}
+
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&filter).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditFilterDelete, "filter", filter.ID, filter, nil, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Delete error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
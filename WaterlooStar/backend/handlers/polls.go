@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	minPollOptions = 2
+	maxPollOptions = 10
+)
+
+type PollVoteRequest struct {
+	OptionIDs []uint `json:"option_ids"`
+}
+
+// preparePoll validates a poll submitted with a new post and clears any
+// client-supplied IDs so that gorm creates fresh rows.
+func preparePoll(poll *models.Poll, now time.Time) error {
+	poll.ID, poll.PostID = 0, 0
+	poll.Question = strings.TrimSpace(poll.Question)
+	if poll.Question == "" {
+		return fmt.Errorf("poll question is required")
+	}
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return fmt.Errorf("polls need between %d and %d options", minPollOptions, maxPollOptions)
+	}
+	if poll.EndsAt != nil && !poll.EndsAt.After(now) {
+		return fmt.Errorf("poll ends_at must be in the future")
+	}
+	anonymous := poll.IsAnonymous()
+	poll.Anonymous = &anonymous
+	for i := range poll.Options {
+		option := &poll.Options[i]
+		option.ID, option.PollID, option.Position = 0, 0, i
+		option.Text = strings.TrimSpace(option.Text)
+		if option.Text == "" {
+			return fmt.Errorf("poll options cannot be empty")
+		}
+	}
+	return nil
+}
+
+// preloadPoll loads a post's poll with its options in display order
+func preloadPoll(db *gorm.DB) *gorm.DB {
+	return db.Preload("Poll").Preload("Poll.Options", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position asc")
+	})
+}
+
+// annotatePolls fills in vote counts and the viewer's own votes for the polls
+// of the given posts. viewerID is 0 for guests. Results are hidden from
+// viewers who have not voted yet when the poll asks for it.
+func annotatePolls(posts []models.Post, viewerID uint) {
+	polls := make(map[uint]*models.Poll)
+	for i := range posts {
+		if posts[i].Poll != nil {
+			polls[posts[i].Poll.ID] = posts[i].Poll
+		}
+	}
+	if len(polls) == 0 {
+		return
+	}
+	pollIDs := make([]uint, 0, len(polls))
+	for id := range polls {
+		pollIDs = append(pollIDs, id)
+	}
+
+	var counts []struct {
+		PollID   uint
+		OptionID uint
+		Votes    uint
+	}
+	storage.DB.Model(&models.PollVote{}).Select("poll_id, option_id, COUNT(*) AS votes").
+		Where("poll_id IN ?", pollIDs).Group("poll_id, option_id").Scan(&counts)
+
+	var voters []struct {
+		PollID   uint
+		OptionID uint
+		UserID   uint
+		Username string
+	}
+	storage.DB.Table("poll_votes").Select("poll_votes.poll_id, poll_votes.option_id, poll_votes.user_id, users.username").
+		Joins("JOIN users ON users.id = poll_votes.user_id").
+		Joins("JOIN polls ON polls.id = poll_votes.poll_id").
+		Where("poll_votes.poll_id IN ? AND (polls.anonymous = ? OR poll_votes.user_id = ?)", pollIDs, false, viewerID).
+		Order("poll_votes.created_at asc").Scan(&voters)
+
+	optionVotes := make(map[uint]uint)
+	totals := make(map[uint]uint)
+	for _, c := range counts {
+		optionVotes[c.OptionID] = c.Votes
+		totals[c.PollID] += c.Votes
+	}
+	optionVoters := make(map[uint][]string)
+	myVotes := make(map[uint][]uint)
+	for _, v := range voters {
+		if viewerID != 0 && v.UserID == viewerID {
+			myVotes[v.PollID] = append(myVotes[v.PollID], v.OptionID)
+		}
+		if !polls[v.PollID].IsAnonymous() {
+			optionVoters[v.OptionID] = append(optionVoters[v.OptionID], v.Username)
+		}
+	}
+
+	now := time.Now()
+	for _, poll := range polls {
+		poll.IsClosed = poll.IsClosedAt(now)
+		poll.MyVotes = myVotes[poll.ID]
+		if poll.MyVotes == nil {
+			poll.MyVotes = []uint{}
+		}
+		poll.ResultsHidden = poll.HideResultsUntilVote && !poll.IsClosed && len(poll.MyVotes) == 0
+		if poll.ResultsHidden {
+			continue
+		}
+		poll.TotalVotes = totals[poll.ID]
+		for i := range poll.Options {
+			poll.Options[i].Votes = optionVotes[poll.Options[i].ID]
+			poll.Options[i].Voters = optionVoters[poll.Options[i].ID]
+		}
+	}
+}
+
+// VotePoll records the current user's vote: POST /api/posts/{id}/poll/vote
+// Voting again replaces the previous choice.
+func VotePoll(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := postIDFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+
+	var req PollVoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	req.OptionIDs = uniqueIDs(req.OptionIDs)
+
+	var post models.Post
+	if err := preloadPoll(storage.DB).First(&post, postID).Error; err != nil || !visibility.FromRequest(r).CanSeePost(storage.DB, &post) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+	if post.Poll == nil {
+		http.Error(w, "Post has no poll", http.StatusNotFound)
+		return
+	}
+	if post.IsLocked || post.Poll.IsClosedAt(time.Now()) {
+		http.Error(w, "Poll is closed", http.StatusForbidden)
+		return
+	}
+	if !checkBan(w, userClaims.UserID, post.Section) {
+		return
+	}
+
+	if len(req.OptionIDs) == 0 {
+		http.Error(w, "Choose at least one option", http.StatusBadRequest)
+		return
+	}
+	if !post.Poll.MultipleChoice && len(req.OptionIDs) > 1 {
+		http.Error(w, "This poll allows only one choice", http.StatusBadRequest)
+		return
+	}
+	validOptions := make(map[uint]bool, len(post.Poll.Options))
+	for _, option := range post.Poll.Options {
+		validOptions[option.ID] = true
+	}
+	for _, id := range req.OptionIDs {
+		if !validOptions[id] {
+			http.Error(w, "Invalid poll option", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the poll so that concurrent votes by the same user cannot both
+		// pass the delete below and record two options of a single-choice poll
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.Poll{}, post.Poll.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("poll_id = ? AND user_id = ?", post.Poll.ID, userClaims.UserID).Delete(&models.PollVote{}).Error; err != nil {
+			return err
+		}
+		votes := make([]models.PollVote, len(req.OptionIDs))
+		for i, optionID := range req.OptionIDs {
+			votes[i] = models.PollVote{PollID: post.Poll.ID, OptionID: optionID, UserID: userClaims.UserID}
+		}
+		return tx.Create(&votes).Error
+	})
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Failed to record vote", http.StatusInternalServerError)
+		return
+	}
+
+	posts := []models.Post{post}
+	annotatePolls(posts, userClaims.UserID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(posts[0].Poll)
+}
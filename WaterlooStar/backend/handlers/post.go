@@ -1,20 +1,111 @@
 package handlers
 
 import (
+	"WaterlooStar/backend/mention"
 	"WaterlooStar/backend/middleware"
 	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/realtime"
+	"WaterlooStar/backend/render"
 	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/subscription"
+	"WaterlooStar/backend/visibility"
+	"WaterlooStar/backend/webhook"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
+// postSortOrders maps the ?sort= query parameter to an ORDER BY clause
+var postSortOrders = map[string]string{
+	"new":   "published_at desc",
+	"top":   "likes desc, published_at desc",
+	"views": "views desc, published_at desc",
+}
+
+// pinnedOrder keeps pinned posts above everything else. Global pins apply to
+// every listing, section pins only when browsing that section.
+func pinnedOrder(section string) string {
+	if section != "" {
+		return "CASE WHEN is_pinned THEN pin_order END ASC NULLS LAST"
+	}
+	return fmt.Sprintf("CASE WHEN is_pinned AND pin_scope = '%s' THEN pin_order END ASC NULLS LAST", models.PinScopeGlobal)
+}
+
+// pathID extracts the numeric {id} from paths like {prefix}{id}/...
+func pathID(r *http.Request, prefix string) (uint, error) {
+	path := strings.TrimPrefix(r.URL.Path, prefix)
+	parts := strings.Split(path, "/")
+	id, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+// postIDFromPath extracts {id} from /api/posts/{id}/...
+func postIDFromPath(r *http.Request) (uint, error) {
+	return pathID(r, "/api/posts/")
+}
+
+// annotateViewerState fills in the per-viewer IsLiked, IsBookmarked, IsSubscribed
+// and IsOwn fields
+func annotateViewerState(posts []models.Post, userID uint) {
+	if len(posts) == 0 {
+		return
+	}
+	postIDs := make([]uint, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+
+	var likedIDs, bookmarkedIDs, subscribedIDs []uint
+	storage.DB.Model(&models.PostLike{}).Where("user_id = ? AND post_id IN ?", userID, postIDs).Pluck("post_id", &likedIDs)
+	storage.DB.Model(&models.Bookmark{}).Where("user_id = ? AND post_id IN ?", userID, postIDs).Pluck("post_id", &bookmarkedIDs)
+	storage.DB.Model(&models.ThreadSubscription{}).Where("user_id = ? AND post_id IN ?", userID, postIDs).Pluck("post_id", &subscribedIDs)
+
+	liked := make(map[uint]bool, len(likedIDs))
+	for _, id := range likedIDs {
+		liked[id] = true
+	}
+	bookmarked := make(map[uint]bool, len(bookmarkedIDs))
+	for _, id := range bookmarkedIDs {
+		bookmarked[id] = true
+	}
+	subscribed := make(map[uint]bool, len(subscribedIDs))
+	for _, id := range subscribedIDs {
+		subscribed[id] = true
+	}
+	for i := range posts {
+		posts[i].IsLiked = liked[posts[i].ID]
+		posts[i].IsSubscribed = subscribed[posts[i].ID]
+		posts[i].IsBookmarked = bookmarked[posts[i].ID]
+		posts[i].IsOwn = posts[i].AuthorID == userID
+	}
+}
+
 func GetPosts(w http.ResponseWriter, r *http.Request) {
 	section := r.URL.Query().Get("section")
+	sortOrder, ok := postSortOrders[r.URL.Query().Get("sort")]
+	if !ok {
+		sortOrder = postSortOrders["new"]
+	}
+	viewer := visibility.FromRequest(r)
 	var posts []models.Post
-	query := storage.DB.Preload("Comments").Order("created_at desc")
+	query := storage.DB.Preload("Attachments").Preload("Comments", viewer.Comments).Preload("Comments.Mentions").
+		Scopes(preloadPoll, preloadMentions, viewer.Posts).Order(pinnedOrder(section)).Order(sortOrder)
 	if section != "" {
-		query = query.Where("section = ?", section)
+		// Global pins top every section's listing, not only their own
+		query = query.Where("(section = ? OR (is_pinned AND pin_scope = ?))", section, models.PinScopeGlobal)
+	}
+	if r.URL.Query().Get("featured") == "true" {
+		query = query.Where("is_featured = ?", true)
 	}
 	if err := query.Find(&posts).Error; err != nil {
 		log.Println("DB Query error:", err)
@@ -22,14 +113,14 @@ func GetPosts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if current user liked each post (if authenticated)
-	userClaims, authenticated := middleware.GetUserFromContext(r)
-	if authenticated {
-		for i := range posts {
-			var existingLike models.PostLike
-			err := storage.DB.Where("user_id = ? AND post_id = ?", userClaims.UserID, posts[i].ID).First(&existingLike).Error
-			posts[i].IsLiked = (err == nil)
-		}
+	// Check if current user liked or bookmarked each post (if authenticated)
+	if viewer.UserID != 0 {
+		annotateViewerState(posts, viewer.UserID)
+	}
+	annotatePolls(posts, viewer.UserID)
+	viewer.AdjustLikeCounts(storage.DB, posts)
+	if viewer.IsModerator {
+		revealAuthors(posts)
 	}
 
 	json.NewEncoder(w).Encode(posts)
@@ -48,12 +139,19 @@ func CreatePost(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Section is required", http.StatusBadRequest)
 		return
 	}
+	if !checkBan(w, userClaims.UserID, section) {
+		return
+	}
 
 	var post models.Post
 	if err := json.NewDecoder(r.Body).Decode(&post); err != nil {
 		http.Error(w, "Invalid body", http.StatusBadRequest)
 		return
 	}
+	if !render.ValidFormat(post.ContentFormat) {
+		http.Error(w, "content_format must be plain or markdown", http.StatusBadRequest)
+		return
+	}
 
 	// Get user information
 	var user models.User
@@ -66,13 +164,112 @@ func CreatePost(w http.ResponseWriter, r *http.Request) {
 	post.Section = section // Always use section from URL, ignore body
 	post.AuthorID = userClaims.UserID
 	post.Author = user.Username // Use username instead of manual input
+	if post.IsAnonymous && !sectionAllowsAnonymous(section) {
+		http.Error(w, errAnonymousNotAllowed.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Pin, lock and feature flags are moderator-only, see SetPostPin and friends
+	post.IsPinned, post.PinScope, post.PinOrder = false, "", 0
+	post.IsLocked, post.IsFeatured, post.IsHidden = false, false, false
+	post.Mentions = nil // Parsed from the content on save
+
+	if err := applyPostStatus(&post, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if post.Poll != nil {
+		if err := preparePoll(post.Poll, time.Now()); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Drafts are checked when they are published, see PublishDraft
+	var reviewReasons []string
+	if post.Status != models.PostStatusDraft {
+		result, ok := runContentPolicy(w, r, &user, models.ReportTargetPost, 0, post.Section, post.Title, post.Content)
+		if !ok {
+			return
+		}
+		post.Title, post.Content, post.ContentHash = result.Title, result.Content, result.Fingerprint
+		if result.Flagged {
+			post.IsHidden = true
+			reviewReasons = result.Reasons
+		}
+	}
 
-	if err := storage.DB.Create(&post).Error; err != nil {
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		mentions, err := mention.Save(tx, post.ID, nil, post.AuthorID, post.IsAnonymous, post.Content)
+		if err != nil {
+			return err
+		}
+		post.Mentions = mentions
+		if err := subscription.Subscribe(tx, post.AuthorID, post.ID); err != nil {
+			return err
+		}
+		if post.IsHidden {
+			if err := queueForReview(tx, models.ReportTargetPost, post.ID, post.AuthorID, post.Section, reviewReasons); err != nil {
+				return err
+			}
+		}
+		if post.IsAnonymous {
+			// The post ID is needed for the pseudonym, so the real username is replaced after insert
+			pseudonym, err := threadPseudonym(tx, post.ID, post.AuthorID)
+			if err != nil {
+				return err
+			}
+			post.Author = pseudonym
+			if err := tx.Model(&post).UpdateColumn("author", pseudonym).Error; err != nil {
+				return err
+			}
+		}
+		return linkAttachments(tx, userClaims.UserID, post.AttachmentIDs, "post_id", post.ID)
+	})
+	if errors.Is(err, errInvalidAttachments) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		log.Println("DB Insert error:", err)
 		http.Error(w, "Database error", http.StatusInternalServerError)
 		return
 	}
+	storage.DB.Where("post_id = ?", post.ID).Find(&post.Attachments)
+	if post.IsVisible() {
+		mention.Notify(storage.DB, post.Mentions, &post, nil)
+		realtime.PublishPost(&post)
+		webhook.PostCreated(&post)
+	}
+	post.IsOwn = true
+	if post.Poll != nil {
+		annotatePolls([]models.Post{post}, userClaims.UserID)
+	}
 
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(post)
 }
+
+// applyPostStatus validates the requested status and publish time of a new or
+// edited post. Posts without a status are published immediately.
+func applyPostStatus(post *models.Post, now time.Time) error {
+	switch post.Status {
+	case "", models.PostStatusPublished:
+		post.Status = models.PostStatusPublished
+		post.PublishAt = nil
+		post.PublishedAt = &now
+	case models.PostStatusScheduled:
+		if post.PublishAt == nil || !post.PublishAt.After(now) {
+			return fmt.Errorf("publish_at must be in the future for scheduled posts")
+		}
+		post.PublishedAt = nil
+	case models.PostStatusDraft:
+		post.PublishedAt = nil
+	default:
+		return fmt.Errorf("status must be one of draft, scheduled or published")
+	}
+	return nil
+}
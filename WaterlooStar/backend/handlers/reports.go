@@ -0,0 +1,451 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/webhook"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxReportDetailsLength = 2000
+
+type ReportRequest struct {
+	TargetType string `json:"target_type"` // "post", "comment" or "user"
+	TargetID   uint   `json:"target_id"`
+	Reason     string `json:"reason"`
+	Details    string `json:"details"`
+}
+
+type ResolveReportRequest struct {
+	Action      string `json:"action"` // "dismiss", "remove_content", "warn" or "ban"
+	Note        string `json:"note"`
+	BanSection  string `json:"ban_section"`  // Empty for a site-wide ban
+	BanDuration int    `json:"ban_duration"` // In hours, 0 for a permanent ban
+}
+
+// SubmittedReport is what reporters get back. It leaves out the reported
+// user, who may be the author of anonymous content.
+type SubmittedReport struct {
+	ID         uint      `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	TargetType string    `json:"target_type"`
+	TargetID   uint      `json:"target_id"`
+	Reason     string    `json:"reason"`
+	Details    string    `json:"details,omitempty"`
+	Status     string    `json:"status"`
+}
+
+// ReportQueueItem is a report with the number of open reports on its target
+type ReportQueueItem struct {
+	models.Report
+	OpenReports int64 `json:"open_reports"`
+}
+
+type ReportQueueResponse struct {
+	Reports []ReportQueueItem `json:"reports"`
+	Total   int64             `json:"total"`
+	Page    int               `json:"page"`
+	Limit   int               `json:"limit"`
+}
+
+var errReportTargetNotFound = errors.New("reported content not found")
+
+// reportTarget looks up what a report points at and returns the section it
+// belongs to (empty for users) and the responsible user.
+func reportTarget(db *gorm.DB, targetType string, targetID uint) (section string, userID uint, err error) {
+	switch targetType {
+	case models.ReportTargetPost:
+		var post models.Post
+		if err := db.First(&post, targetID).Error; err != nil {
+			return "", 0, errReportTargetNotFound
+		}
+		return post.Section, post.AuthorID, nil
+	case models.ReportTargetComment:
+		var comment models.Comment
+		if err := db.First(&comment, targetID).Error; err != nil {
+			return "", 0, errReportTargetNotFound
+		}
+		var post models.Post
+		db.Unscoped().Select("section").First(&post, comment.PostID)
+		return post.Section, comment.AuthorID, nil
+	case models.ReportTargetUser:
+		var user models.User
+		if err := db.First(&user, targetID).Error; err != nil {
+			return "", 0, errReportTargetNotFound
+		}
+		return "", user.ID, nil
+	}
+	return "", 0, errReportTargetNotFound
+}
+
+// setContentHidden hides or unhides a reported post or comment
+func setContentHidden(tx *gorm.DB, targetType string, targetID uint, hidden bool) error {
+	switch targetType {
+	case models.ReportTargetPost:
+		return tx.Model(&models.Post{}).Where("id = ?", targetID).UpdateColumn("is_hidden", hidden).Error
+	case models.ReportTargetComment:
+		return tx.Model(&models.Comment{}).Where("id = ?", targetID).UpdateColumn("is_hidden", hidden).Error
+	}
+	return nil
+}
+
+// CreateReport flags content or a user for review: POST /api/reports
+// Once config.ReportHideThreshold users have reported a post or comment it is
+// hidden until a moderator resolves the reports.
+func CreateReport(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req ReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if !models.ReportReasons[req.Reason] {
+		http.Error(w, "Invalid report reason", http.StatusBadRequest)
+		return
+	}
+	req.Details = strings.TrimSpace(req.Details)
+	if len(req.Details) > maxReportDetailsLength {
+		http.Error(w, "Report details are too long", http.StatusBadRequest)
+		return
+	}
+
+	section, targetUserID, err := reportTarget(storage.DB, req.TargetType, req.TargetID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if targetUserID == userClaims.UserID {
+		http.Error(w, "You cannot report yourself", http.StatusBadRequest)
+		return
+	}
+
+	report := models.Report{
+		ReporterID:   userClaims.UserID,
+		TargetType:   req.TargetType,
+		TargetID:     req.TargetID,
+		TargetUserID: targetUserID,
+		Section:      section,
+		Reason:       req.Reason,
+		Details:      req.Details,
+		Status:       models.ReportStatusOpen,
+	}
+
+	var hidden bool
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&report)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errDuplicateReport
+		}
+
+		var openReports int64
+		tx.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", report.TargetType, report.TargetID, models.ReportStatusOpen).
+			Count(&openReports)
+		if req.TargetType != models.ReportTargetUser && openReports >= int64(config.ReportHideThreshold) {
+			hidden = true
+			return setContentHidden(tx, report.TargetType, report.TargetID, true)
+		}
+		return nil
+	})
+	if errors.Is(err, errDuplicateReport) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if hidden {
+		log.Printf("🚩 %s %d hidden after reaching %d reports", report.TargetType, report.TargetID, config.ReportHideThreshold)
+	}
+	webhook.ReportCreated(&report)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(SubmittedReport{
+		ID:         report.ID,
+		CreatedAt:  report.CreatedAt,
+		TargetType: report.TargetType,
+		TargetID:   report.TargetID,
+		Reason:     report.Reason,
+		Details:    report.Details,
+		Status:     report.Status,
+	})
+}
+
+var (
+	errDuplicateReport = errors.New("you have already reported this")
+	errReportResolved  = errors.New("report is already resolved")
+)
+
+// GetModReports lists reports for moderators, oldest open reports first:
+// GET /api/mod/reports?status=open&section=&target_type=&page=&limit=
+func GetModReports(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireModerator(w, r); !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.ReportStatusOpen
+	}
+	query := storage.DB.Model(&models.Report{})
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if section := r.URL.Query().Get("section"); section != "" {
+		query = query.Where("section = ?", section)
+	}
+	if targetType := r.URL.Query().Get("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+	query = query.Session(&gorm.Session{})
+
+	page, limit := parsePagination(r)
+	response := ReportQueueResponse{Page: page, Limit: limit, Reports: []ReportQueueItem{}}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	var reports []models.Report
+	if err := query.Order("created_at asc").Offset((page - 1) * limit).Limit(limit).Find(&reports).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	for _, report := range reports {
+		item := ReportQueueItem{Report: report}
+		storage.DB.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", report.TargetType, report.TargetID, models.ReportStatusOpen).
+			Count(&item.OpenReports)
+		response.Reports = append(response.Reports, item)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// ResolveReport acts on a report: POST /api/mod/reports/{id}/resolve
+// The action applies to the reported target and closes every open report on it.
+// Reports can only be resolved once.
+func ResolveReport(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := pathID(r, "/api/mod/reports/")
+	if err != nil {
+		http.Error(w, "Invalid report ID", http.StatusBadRequest)
+		return
+	}
+
+	var req ResolveReportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+
+	var report models.Report
+	if err := storage.DB.First(&report, reportID).Error; err != nil {
+		http.Error(w, "Report not found", http.StatusNotFound)
+		return
+	}
+	if report.Status != models.ReportStatusOpen {
+		http.Error(w, errReportResolved.Error(), http.StatusConflict)
+		return
+	}
+
+	status := models.ReportStatusActioned
+	switch req.Action {
+	case models.ModActionDismiss:
+		status = models.ReportStatusDismissed
+	case models.ModActionRemoveContent:
+		if report.TargetType == models.ReportTargetUser {
+			http.Error(w, "Users cannot be removed, ban them instead", http.StatusBadRequest)
+			return
+		}
+	case models.ModActionWarn, models.ModActionBan:
+		if report.TargetUserID == 0 {
+			http.Error(w, "Reported content has no author", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Action must be dismiss, remove_content, warn or ban", http.StatusBadRequest)
+		return
+	}
+	if req.BanDuration < 0 {
+		http.Error(w, "ban_duration cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	var ban *models.Ban
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		// Claim the report first so that a concurrent resolve cannot act twice
+		claim := tx.Model(&models.Report{}).Where("id = ? AND status = ?", report.ID, models.ReportStatusOpen).Update("status", status)
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			return errReportResolved
+		}
+
+		switch req.Action {
+		case models.ModActionDismiss:
+			// Undo an automatic hide, the content was fine
+			if err := setContentHidden(tx, report.TargetType, report.TargetID, false); err != nil {
+				return err
+			}
+		case models.ModActionRemoveContent:
+			if err := removeReportedContent(tx, r, moderator, report.TargetType, report.TargetID, req.Note); err != nil {
+				return err
+			}
+		case models.ModActionWarn:
+			if _, err := recordAudit(tx, r, moderator, models.AuditUserWarn, "user", report.TargetUserID, nil, nil, req.Note); err != nil {
+				return err
+			}
+		case models.ModActionBan:
+			var target models.User
+			if err := tx.First(&target, report.TargetUserID).Error; err != nil {
+				return err
+			}
+			var err error
+			ban, err = issueBan(tx, moderator, &target, req.BanSection, req.Note, req.BanDuration, now)
+			if err != nil {
+				return err
+			}
+			if _, err := recordAudit(tx, r, moderator, models.AuditUserBan, "user", report.TargetUserID, nil, ban, req.Note); err != nil {
+				return err
+			}
+		}
+
+		action := models.ModerationAction{
+			ModeratorID:  moderator.ID,
+			ReportID:     &report.ID,
+			Action:       req.Action,
+			TargetType:   report.TargetType,
+			TargetID:     report.TargetID,
+			TargetUserID: report.TargetUserID,
+			Note:         req.Note,
+		}
+		if err := tx.Create(&action).Error; err != nil {
+			return err
+		}
+		if _, err := recordAudit(tx, r, moderator, models.AuditReportResolve, "report", report.ID, report, action, req.Note); err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Report{}).
+			Where("target_type = ? AND target_id = ? AND (status = ? OR id = ?)", report.TargetType, report.TargetID, models.ReportStatusOpen, report.ID).
+			Updates(map[string]interface{}{
+				"status":         status,
+				"resolved_by_id": moderator.ID,
+				"resolved_at":    now,
+				"resolution":     req.Action,
+			}).Error
+	})
+	if errors.Is(err, errReportResolved) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if status := banErrorStatus(err); status != 0 {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🛡️ Moderator %s resolved report %d with %s", moderator.Username, report.ID, req.Action)
+	notifyReportOutcome(&report, moderator, req, ban)
+
+	storage.DB.First(&report, report.ID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// removeReportedContent soft-deletes a reported post or comment and links it
+// to the audit entry that records the removal
+func removeReportedContent(tx *gorm.DB, r *http.Request, moderator *models.User, targetType string, targetID uint, note string) error {
+	switch targetType {
+	case models.ReportTargetPost:
+		var post models.Post
+		if err := tx.First(&post, targetID).Error; err != nil {
+			return nil // Already gone
+		}
+		post.RevealAuthor = true // The audit log keeps who wrote it
+		entry, err := recordAudit(tx, r, moderator, models.AuditContentRemove, targetType, post.ID, post, nil, note)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&post).UpdateColumn("deletion_audit_id", entry.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&post).Error
+	case models.ReportTargetComment:
+		var comment models.Comment
+		if err := tx.First(&comment, targetID).Error; err != nil {
+			return nil
+		}
+		comment.RevealAuthor = true
+		entry, err := recordAudit(tx, r, moderator, models.AuditContentRemove, targetType, comment.ID, comment, nil, note)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&comment).UpdateColumn("deletion_audit_id", entry.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&comment).Error
+	}
+	return nil
+}
+
+// notifyReportOutcome tells the reported user what a moderator did about it
+func notifyReportOutcome(report *models.Report, moderator *models.User, req ResolveReportRequest, ban *models.Ban) {
+	var message string
+	switch req.Action {
+	case models.ModActionRemoveContent:
+		message = "A moderator removed your " + report.TargetType
+	case models.ModActionWarn:
+		message = "A moderator warned you"
+		if report.TargetType != models.ReportTargetUser {
+			message += " about your " + report.TargetType
+		}
+	case models.ModActionBan:
+		message = banMessage(ban)
+	default:
+		return
+	}
+	if req.Note != "" {
+		message += ". Reason: " + req.Note
+	}
+
+	var postID *uint
+	if report.TargetType == models.ReportTargetPost && req.Action != models.ModActionRemoveContent {
+		postID = &report.TargetID
+	}
+	notifyModeration(report.TargetUserID, moderator.ID, postID, message)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SectionUpdateRequest edits section settings. Nil fields are left unchanged.
+type SectionUpdateRequest struct {
+	Name           *string `json:"name"`
+	Description    *string `json:"description"`
+	AllowAnonymous *bool   `json:"allow_anonymous"`
+}
+
+// GetSections lists all sections and their settings: GET /api/sections
+func GetSections(w http.ResponseWriter, r *http.Request) {
+	var sections []models.Section
+	if err := storage.DB.Order("id asc").Find(&sections).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sections)
+}
+
+// UpdateSection edits a section's settings: PUT /api/sections/{slug} (moderators only)
+func UpdateSection(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	slug := strings.TrimPrefix(r.URL.Path, "/api/sections/")
+	var section models.Section
+	if err := storage.DB.Where("slug = ?", slug).First(&section).Error; err != nil {
+		http.Error(w, "Section not found", http.StatusNotFound)
+		return
+	}
+
+	var req SectionUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	before := section
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			http.Error(w, "Section name cannot be empty", http.StatusBadRequest)
+			return
+		}
+		section.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		section.Description = *req.Description
+	}
+	if req.AllowAnonymous != nil {
+		section.AllowAnonymous = *req.AllowAnonymous
+	}
+
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&section).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditSectionUpdate, "section", section.ID, before, section, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🗂️ Moderator %s updated section %s", moderator.Username, section.Slug)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(section)
+}
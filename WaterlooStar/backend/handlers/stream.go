@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/realtime"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxStreamTopics limits how many topics one stream can subscribe to
+const maxStreamTopics = 20
+
+// streamHeartbeat keeps idle streams from being closed by proxies
+const streamHeartbeat = 25 * time.Second
+
+// streamTopics resolves the ?topics= list to hub topics the viewer may read:
+// section:{slug}, post:{id} and notifications (the viewer's own)
+func streamTopics(viewer visibility.Viewer, param string) ([]string, int, error) {
+	var topics []string
+	for _, name := range strings.Split(param, ",") {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+			continue
+		case name == "notifications":
+			if viewer.UserID == 0 {
+				return nil, http.StatusUnauthorized, fmt.Errorf("Authentication required for notifications")
+			}
+			topics = append(topics, realtime.UserTopic(viewer.UserID))
+		case strings.HasPrefix(name, "section:"):
+			slug := strings.TrimPrefix(name, "section:")
+			var count int64
+			storage.DB.Model(&models.Section{}).Where("slug = ?", slug).Count(&count)
+			if count == 0 {
+				return nil, http.StatusNotFound, fmt.Errorf("Section %s not found", slug)
+			}
+			topics = append(topics, realtime.SectionTopic(slug))
+		case strings.HasPrefix(name, "post:"):
+			id, err := strconv.ParseUint(strings.TrimPrefix(name, "post:"), 10, 32)
+			if err != nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("Invalid topic %s", name)
+			}
+			var post models.Post
+			if err := storage.DB.First(&post, id).Error; err != nil || !viewer.CanSeePost(storage.DB, &post) {
+				return nil, http.StatusNotFound, fmt.Errorf("Post %d not found", id)
+			}
+			topics = append(topics, realtime.PostTopic(post.ID))
+		default:
+			return nil, http.StatusBadRequest, fmt.Errorf("Unknown topic %s", name)
+		}
+	}
+	if len(topics) == 0 {
+		return nil, http.StatusBadRequest, fmt.Errorf("At least one topic is required")
+	}
+	if len(topics) > maxStreamTopics {
+		return nil, http.StatusBadRequest, fmt.Errorf("At most %d topics are allowed", maxStreamTopics)
+	}
+	return topics, 0, nil
+}
+
+// authorFilter hides live events by users the viewer blocked, was blocked by
+// or muted. The list is loaded once, so changes apply from the next connection.
+func authorFilter(viewer visibility.Viewer) func(realtime.Event) bool {
+	unwanted := viewer.UnwantedAuthors(storage.DB)
+	return func(e realtime.Event) bool {
+		return e.AuthorID == 0 || !unwanted[e.AuthorID]
+	}
+}
+
+// writeStreamEvent writes one event as an SSE frame. The data line holds
+// {"topic", "data", "truncated"}; truncated events should be refetched.
+func writeStreamEvent(w http.ResponseWriter, e realtime.Event) error {
+	payload, err := json.Marshal(struct {
+		Topic     string          `json:"topic"`
+		Data      json.RawMessage `json:"data,omitempty"`
+		Truncated bool            `json:"truncated,omitempty"`
+	}{e.Topic, e.Data, e.Truncated})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, payload)
+	return err
+}
+
+// Stream sends live updates as Server-Sent Events:
+// GET /api/stream?topics=section:housing,post:12,notifications
+// Authentication is optional except for notifications. EventSource clients
+// can pass the JWT as ?access_token=. Reconnecting with Last-Event-ID (or
+// ?last_event_id=) replays recent events that were missed.
+func Stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || realtime.Default == nil {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	viewer := visibility.FromRequest(r)
+	topics, status, err := streamTopics(viewer, r.URL.Query().Get("topics"))
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("last_event_id")
+	}
+	resumeFrom, _ := strconv.ParseInt(lastEventID, 10, 64)
+
+	sub, replay := realtime.Default.Subscribe(topics, authorFilter(viewer), resumeFrom)
+	defer realtime.Default.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, e := range replay {
+		if writeStreamEvent(w, e) != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// Too far behind; the client reconnects with Last-Event-ID
+				return
+			}
+			if writeStreamEvent(w, e) != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
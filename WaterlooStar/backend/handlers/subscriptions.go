@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/subscription"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type SubscriptionListResponse struct {
+	Subscriptions []models.ThreadSubscription `json:"subscriptions"`
+	Total         int64                       `json:"total"`
+	Page          int                         `json:"page"`
+	Limit         int                         `json:"limit"`
+}
+
+// SubscribePost notifies the current user of new comments on a post:
+// PUT /api/posts/{id}/subscription
+func SubscribePost(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := postIDFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+	var post models.Post
+	if err := storage.DB.First(&post, postID).Error; err != nil || !visibility.FromRequest(r).CanSeePost(storage.DB, &post) {
+		http.Error(w, "Post not found", http.StatusNotFound)
+		return
+	}
+
+	if err := subscription.Subscribe(storage.DB, userClaims.UserID, post.ID); err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UnsubscribePost stops comment notifications for a post, including the
+// reply notifications of its author: DELETE /api/posts/{id}/subscription
+func UnsubscribePost(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	postID, err := postIDFromPath(r)
+	if err != nil {
+		http.Error(w, "Invalid post ID", http.StatusBadRequest)
+		return
+	}
+	if err := subscription.Unsubscribe(storage.DB, userClaims.UserID, postID); err != nil {
+		log.Println("DB Delete error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetMySubscriptions lists the posts the current user is subscribed to,
+// newest subscription first: GET /api/me/subscriptions?page=&limit=
+func GetMySubscriptions(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	page, limit := parsePagination(r)
+	viewer := visibility.FromRequest(r)
+	visiblePostIDs := storage.DB.Model(&models.Post{}).Select("posts.id").Scopes(viewer.Posts)
+	query := storage.DB.Model(&models.ThreadSubscription{}).InnerJoins("Post").
+		Where("thread_subscriptions.user_id = ? AND thread_subscriptions.post_id IN (?)", userClaims.UserID, visiblePostIDs).
+		Session(&gorm.Session{})
+
+	response := SubscriptionListResponse{Page: page, Limit: limit, Subscriptions: []models.ThreadSubscription{}}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	err := query.Order("thread_subscriptions.created_at desc").
+		Offset((page - 1) * limit).Limit(limit).Find(&response.Subscriptions).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	posts := make([]models.Post, len(response.Subscriptions))
+	for i := range response.Subscriptions {
+		posts[i] = response.Subscriptions[i].Post
+	}
+	annotateViewerState(posts, userClaims.UserID)
+	viewer.AdjustLikeCounts(storage.DB, posts)
+	for i := range response.Subscriptions {
+		response.Subscriptions[i].Post = posts[i]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
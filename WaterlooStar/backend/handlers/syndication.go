@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/notify"
+	"WaterlooStar/backend/render"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/syndication"
+	"WaterlooStar/backend/visibility"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// syndicationSize is how many of the newest posts a feed lists
+const syndicationSize = 50
+
+// GetSyndicationFeed serves the newest posts as RSS or Atom for feed readers:
+// GET /feeds/{section}.rss, /feeds/tags/{tag}.rss or /feeds/users/{user_id}.rss,
+// or .atom instead of .rss. Feeds are public and show what a guest sees.
+// ETag and Last-Modified allow conditional requests.
+func GetSyndicationFeed(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/feeds/")
+	format := path.Ext(name)
+	if format != ".rss" && format != ".atom" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	parts := strings.Split(strings.TrimSuffix(name, format), "/")
+
+	// Same visibility as GetPosts for a guest: published, not hidden, not
+	// deleted and not by shadowbanned users
+	query := storage.DB.Scopes(visibility.Viewer{}.Posts).Order(postSortOrders["new"]).Order("id desc").Limit(syndicationSize)
+	site := strings.TrimRight(config.SiteURL, "/")
+	feed := syndication.Feed{Link: site, Self: strings.TrimRight(config.APIURL, "/") + r.URL.Path}
+
+	switch {
+	case len(parts) == 1 && parts[0] != "":
+		var section models.Section
+		if err := storage.DB.Where("slug = ?", parts[0]).First(&section).Error; err != nil {
+			http.Error(w, "Section not found", http.StatusNotFound)
+			return
+		}
+		query = query.Where("section = ?", section.Slug)
+		feed.Title, feed.Description = section.Name, section.Description
+		feed.Link = site + "/section/" + url.PathEscape(section.Slug)
+	case len(parts) == 2 && parts[0] == "tags":
+		tag, err := url.PathUnescape(parts[1])
+		if tag = normalizeTag(tag); err != nil || tag == "" {
+			http.Error(w, "Invalid tag", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("EXISTS (SELECT 1 FROM unnest(string_to_array(lower(posts.tags), ',')) AS tag WHERE btrim(tag) = ?)", tag)
+		feed.Title = "Posts tagged " + tag
+		feed.Description = fmt.Sprintf("The newest posts tagged %q", tag)
+	case len(parts) == 2 && parts[0] == "users":
+		id, err := strconv.ParseUint(parts[1], 10, 32)
+		if err != nil {
+			http.Error(w, "Invalid user ID", http.StatusBadRequest)
+			return
+		}
+		var user models.User
+		if err := storage.DB.Select("id", "username").First(&user, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.Error(w, "User not found", http.StatusNotFound)
+				return
+			}
+			log.Println("DB Query error:", err)
+			http.Error(w, "Database error", http.StatusInternalServerError)
+			return
+		}
+		// Anonymous posts stay out, they would be attributed to the user
+		query = query.Where("author_id = ? AND NOT is_anonymous", user.ID)
+		feed.Title = "Posts by " + user.Username
+		feed.Description = "The newest posts by " + user.Username
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	var posts []models.Post
+	if err := query.Find(&posts).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	for _, post := range posts {
+		item := syndicationItem(&post)
+		if item.Updated.After(feed.Updated) {
+			feed.Updated = item.Updated
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	var body []byte
+	var err error
+	if format == ".rss" {
+		w.Header().Set("Content-Type", syndication.ContentTypeRSS)
+		body, err = syndication.RSS(feed)
+	} else {
+		w.Header().Set("Content-Type", syndication.ContentTypeAtom)
+		body, err = syndication.Atom(feed)
+	}
+	if err != nil {
+		log.Println("Feed encoding error:", err)
+		http.Error(w, "Failed to build feed", http.StatusInternalServerError)
+		return
+	}
+
+	// The body only changes with the posts, so its hash is a strong ETag.
+	// ServeContent answers If-None-Match and If-Modified-Since with 304.
+	sum := sha256.Sum256(body)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	http.ServeContent(w, r, "", feed.Updated, bytes.NewReader(body))
+}
+
+// syndicationItem turns a post into a feed item. Its GUID is a tag URI
+// (RFC 4151) rather than the post's link, which changes with its section.
+// Posts have no edit time, so items are dated by when they were published.
+func syndicationItem(post *models.Post) syndication.Item {
+	published := post.CreatedAt
+	if post.PublishedAt != nil {
+		published = *post.PublishedAt
+	}
+	content := post.ContentHTML
+	if content == "" {
+		content = render.HTML(post.ContentFormat, post.Content)
+	}
+	host := "localhost"
+	if u, err := url.Parse(config.SiteURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+
+	item := syndication.Item{
+		ID:        fmt.Sprintf("tag:%s,%s:post/%d", host, post.CreatedAt.UTC().Format("2006-01-02"), post.ID),
+		Title:     post.Title,
+		Link:      notify.PostURL(post.Section, post.ID),
+		Author:    post.Author, // A pseudonym for anonymous posts
+		HTML:      content,
+		Published: published,
+		Updated:   published, // UpdatedAt also changes with likes and views
+	}
+	for _, tag := range strings.Split(post.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			item.Categories = append(item.Categories, tag)
+		}
+	}
+	return item
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // Register decoders for image.Decode
+	"image/jpeg"
+	_ "image/png"
+)
+
+const (
+	thumbnailMaxSize   = 320
+	thumbnailMaxPixels = 40_000_000 // Refuse to decode decompression bombs
+)
+
+// makeThumbnail scales an image down to fit in thumbnailMaxSize pixels and
+// encodes it as JPEG. Formats the standard library cannot decode (e.g. WebP)
+// return an error and are stored without a thumbnail.
+func makeThumbnail(data []byte) ([]byte, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if config.Width*config.Height > thumbnailMaxPixels {
+		return nil, fmt.Errorf("image too large for thumbnail: %dx%d", config.Width, config.Height)
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	scale := 1.0
+	if width > height && width > thumbnailMaxSize {
+		scale = float64(thumbnailMaxSize) / float64(width)
+	} else if height >= width && height > thumbnailMaxSize {
+		scale = float64(thumbnailMaxSize) / float64(height)
+	}
+	dstWidth := max(1, int(float64(width)*scale))
+	dstHeight := max(1, int(float64(height)*scale))
+
+	// Nearest-neighbour sampling is good enough for previews
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		srcY := bounds.Min.Y + int(float64(y)/scale)
+		for x := 0; x < dstWidth; x++ {
+			srcX := bounds.Min.X + int(float64(x)/scale)
+			dst.Set(x, y, src.At(srcX, srcY))
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
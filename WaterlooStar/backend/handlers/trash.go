@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/jobs"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Trash item types
+const (
+	TrashPost    = "post"
+	TrashComment = "comment"
+	TrashUser    = "user"
+)
+
+// TrashItem is a deleted post, comment or user. Exactly one of Post, Comment
+// and User is set.
+type TrashItem struct {
+	Type            string          `json:"type"`
+	ID              uint            `json:"id"`
+	DeletedAt       time.Time       `json:"deleted_at"`
+	PurgeAt         time.Time       `json:"purge_at"`                    // When the purge job removes it for good
+	DeletionAuditID *uint           `json:"deletion_audit_id,omitempty"` // Set when a moderator removed it
+	Post            *models.Post    `json:"post,omitempty"`
+	Comment         *models.Comment `json:"comment,omitempty"`
+	User            *models.User    `json:"user,omitempty"`
+}
+
+type TrashResponse struct {
+	Items []TrashItem `json:"items"`
+	Total int64       `json:"total"`
+	Page  int         `json:"page"`
+	Limit int         `json:"limit"`
+}
+
+var errParentDeleted = errors.New("the comment's post is deleted, restore the post first")
+
+// trashType reads the item type from the query string or path and checks that
+// the caller may manage it: moderators handle content, only admins handle users
+func trashType(w http.ResponseWriter, r *http.Request, itemType string) (*models.User, bool) {
+	moderator, ok := requireModerator(w, r)
+	if !ok {
+		return nil, false
+	}
+	switch itemType {
+	case TrashPost, TrashComment:
+	case TrashUser:
+		if moderator.Role != models.RoleAdmin {
+			http.Error(w, "Admin privileges required", http.StatusForbidden)
+			return nil, false
+		}
+	default:
+		http.Error(w, "Type must be post, comment or user", http.StatusBadRequest)
+		return nil, false
+	}
+	return moderator, true
+}
+
+// GetTrash lists recently deleted content, most recently deleted first:
+// GET /api/mod/trash?type=post|comment|user&page=&limit=
+func GetTrash(w http.ResponseWriter, r *http.Request) {
+	itemType := r.URL.Query().Get("type")
+	if itemType == "" {
+		itemType = TrashPost
+	}
+	if _, ok := trashType(w, r, itemType); !ok {
+		return
+	}
+
+	page, limit := parsePagination(r)
+	response := TrashResponse{Items: []TrashItem{}, Page: page, Limit: limit}
+	retention := jobs.TrashRetention()
+
+	var model interface{}
+	switch itemType {
+	case TrashPost:
+		model = &models.Post{}
+	case TrashComment:
+		model = &models.Comment{}
+	case TrashUser:
+		model = &models.User{}
+	}
+	query := storage.DB.Unscoped().Model(model).Where("deleted_at IS NOT NULL").
+		Order("deleted_at desc").Session(&gorm.Session{})
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	pageQuery := query.Offset((page - 1) * limit).Limit(limit)
+
+	var err error
+	switch itemType {
+	case TrashPost:
+		var posts []models.Post
+		if err = pageQuery.Find(&posts).Error; err == nil {
+			for i := range posts {
+				posts[i].RevealAuthor = true
+				response.Items = append(response.Items, TrashItem{
+					Type: itemType, ID: posts[i].ID, DeletedAt: posts[i].DeletedAt.Time,
+					PurgeAt: posts[i].DeletedAt.Time.Add(retention), DeletionAuditID: posts[i].DeletionAuditID, Post: &posts[i],
+				})
+			}
+		}
+	case TrashComment:
+		var comments []models.Comment
+		if err = pageQuery.Find(&comments).Error; err == nil {
+			for i := range comments {
+				comments[i].RevealAuthor = true
+				response.Items = append(response.Items, TrashItem{
+					Type: itemType, ID: comments[i].ID, DeletedAt: comments[i].DeletedAt.Time,
+					PurgeAt: comments[i].DeletedAt.Time.Add(retention), DeletionAuditID: comments[i].DeletionAuditID, Comment: &comments[i],
+				})
+			}
+		}
+	case TrashUser:
+		var users []models.User
+		if err = pageQuery.Find(&users).Error; err == nil {
+			for i := range users {
+				response.Items = append(response.Items, TrashItem{
+					Type: itemType, ID: users[i].ID, DeletedAt: users[i].DeletedAt.Time,
+					PurgeAt: users[i].DeletedAt.Time.Add(retention), DeletionAuditID: users[i].DeletionAuditID, User: &users[i],
+				})
+			}
+		}
+	}
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RestoreTrash undeletes an item: POST /api/mod/trash/{type}/{id}/restore
+// A post comes back with the comments, likes and attachments that were
+// deleted along with it; anything removed before the post stays deleted.
+func RestoreTrash(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/mod/trash/"), "/")
+	if len(parts) != 3 || parts[2] != "restore" {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	itemType := parts[0]
+	moderator, ok := trashType(w, r, itemType)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+
+	var restored interface{}
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		switch itemType {
+		case TrashPost:
+			var post models.Post
+			if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&post, id).Error; err != nil {
+				return err
+			}
+			if err := restorePost(tx, &post); err != nil {
+				return err
+			}
+			restored = post
+		case TrashComment:
+			var comment models.Comment
+			if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&comment, id).Error; err != nil {
+				return err
+			}
+			if err := restoreComment(tx, &comment); err != nil {
+				return err
+			}
+			restored = comment
+		case TrashUser:
+			var user models.User
+			if err := tx.Unscoped().Where("deleted_at IS NOT NULL").First(&user, id).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&user).UpdateColumns(map[string]interface{}{"deleted_at": nil, "deletion_audit_id": nil}).Error; err != nil {
+				return err
+			}
+			_, err := recordAudit(tx, r, moderator, models.AuditUserRestore, itemType, user.ID, nil, user, "")
+			return err
+		}
+		_, err := recordAudit(tx, r, moderator, models.AuditContentRestore, itemType, uint(id), nil, restored, "")
+		return err
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "Deleted item not found", http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errParentDeleted) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("♻️ Moderator %s restored %s %d", moderator.Username, itemType, id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// restorePost undeletes a post together with everything deleted at or after
+// its own deletion time and recounts its likes
+func restorePost(tx *gorm.DB, post *models.Post) error {
+	deletedAt := post.DeletedAt.Time
+	commentIDs := tx.Unscoped().Model(&models.Comment{}).Select("id").Where("post_id = ?", post.ID)
+	err := tx.Unscoped().Model(&models.Attachment{}).
+		Where("(post_id = ? OR comment_id IN (?)) AND deleted_at >= ?", post.ID, commentIDs, deletedAt).
+		UpdateColumn("deleted_at", nil).Error
+	if err != nil {
+		return err
+	}
+	for _, model := range []interface{}{&models.Comment{}, &models.PostLike{}} {
+		err := tx.Unscoped().Model(model).Where("post_id = ? AND deleted_at >= ?", post.ID, deletedAt).
+			UpdateColumn("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+	}
+	err = tx.Unscoped().Model(post).UpdateColumns(map[string]interface{}{"deleted_at": nil, "deletion_audit_id": nil}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Exec("UPDATE posts SET likes = (SELECT COUNT(*) FROM post_likes WHERE post_id = ? AND deleted_at IS NULL) WHERE id = ?",
+		post.ID, post.ID).Error
+}
+
+// restoreComment undeletes a comment and its attachments. The comment's post
+// must not be deleted.
+func restoreComment(tx *gorm.DB, comment *models.Comment) error {
+	var parents int64
+	tx.Model(&models.Post{}).Where("id = ?", comment.PostID).Count(&parents)
+	if parents == 0 {
+		return errParentDeleted
+	}
+	err := tx.Unscoped().Model(&models.Attachment{}).
+		Where("comment_id = ? AND deleted_at >= ?", comment.ID, comment.DeletedAt.Time).
+		UpdateColumn("deleted_at", nil).Error
+	if err != nil {
+		return err
+	}
+	return tx.Unscoped().Model(comment).UpdateColumns(map[string]interface{}{"deleted_at": nil, "deletion_audit_id": nil}).Error
+}
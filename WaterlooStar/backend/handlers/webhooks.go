@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/webhook"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// WebhookRequest creates or edits a webhook. Nil fields are left unchanged
+// on edit; URL and Events are required on create.
+type WebhookRequest struct {
+	URL          *string   `json:"url"`
+	Description  *string   `json:"description"`
+	Events       *[]string `json:"events"`   // e.g. ["post.created", "comment.created"]
+	Sections     *[]string `json:"sections"` // Section slugs, empty for all
+	IsActive     *bool     `json:"is_active"`
+	RotateSecret bool      `json:"rotate_secret"`
+}
+
+// WebhookResponse includes the signing secret when it was just set
+type WebhookResponse struct {
+	models.Webhook
+	Secret string `json:"secret,omitempty"`
+}
+
+type WebhookDeliveryListResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+	Total      int64                    `json:"total"`
+	Page       int                      `json:"page"`
+	Limit      int                      `json:"limit"`
+}
+
+// apply validates req and copies it onto hook
+func (req *WebhookRequest) apply(hook *models.Webhook) string {
+	if req.URL != nil {
+		u, err := url.Parse(strings.TrimSpace(*req.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "url must be an absolute http or https URL"
+		}
+		if !webhook.ValidHost(u.Hostname()) {
+			return "url cannot point at a private, loopback or link-local address"
+		}
+		hook.URL = u.String()
+	}
+	if req.Description != nil {
+		hook.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Events != nil {
+		if len(*req.Events) == 0 {
+			return "events cannot be empty"
+		}
+		for _, event := range *req.Events {
+			if !webhook.ValidEvent(event) {
+				return "Unknown event: " + event
+			}
+		}
+		hook.Events = strings.Join(*req.Events, ",")
+	}
+	if req.Sections != nil {
+		var count int64
+		storage.DB.Model(&models.Section{}).Where("slug IN ?", *req.Sections).Count(&count)
+		if int(count) != len(*req.Sections) {
+			return "Unknown section in sections"
+		}
+		hook.Sections = strings.Join(*req.Sections, ",")
+	}
+	if req.IsActive != nil {
+		if *req.IsActive && !hook.IsActive {
+			// Re-enabled webhooks start counting failures afresh
+			hook.ConsecutiveFailures = 0
+			hook.DisabledAt = nil
+		}
+		hook.IsActive = *req.IsActive
+	}
+	return ""
+}
+
+// loadWebhook loads the webhook in /api/admin/webhooks/{id}/...
+func loadWebhook(w http.ResponseWriter, r *http.Request) (*models.Webhook, bool) {
+	id, err := pathID(r, "/api/admin/webhooks/")
+	if err != nil {
+		http.Error(w, "Invalid webhook ID", http.StatusBadRequest)
+		return nil, false
+	}
+	var hook models.Webhook
+	if err := storage.DB.First(&hook, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Webhook not found", http.StatusNotFound)
+			return nil, false
+		}
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return nil, false
+	}
+	return &hook, true
+}
+
+// GetWebhooks lists all webhooks: GET /api/admin/webhooks (admins only)
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+
+	hooks := []models.Webhook{}
+	if err := storage.DB.Order("id asc").Find(&hooks).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+// CreateWebhook adds a webhook: POST /api/admin/webhooks (admins only) with
+// {"url": "https://...", "events": ["post.created"], "sections": ["events"]}
+// The response holds the signing secret, which is not shown again.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	if req.URL == nil || req.Events == nil {
+		http.Error(w, "url and events are required", http.StatusBadRequest)
+		return
+	}
+	hook := models.Webhook{CreatedByID: admin.ID, IsActive: true}
+	if message := req.apply(&hook); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	secret, err := webhook.NewSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+	hook.Secret = secret
+
+	err = storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&hook).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, admin, models.AuditWebhookCreate, "webhook", hook.ID, nil, hook, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🪝 Admin %s added webhook %d for %s", admin.Username, hook.ID, hook.Events)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(WebhookResponse{Webhook: hook, Secret: secret})
+}
+
+// UpdateWebhook edits a webhook: PUT /api/admin/webhooks/{id} (admins only)
+// Setting is_active re-enables a webhook that was disabled after failures.
+// {"rotate_secret": true} replaces the secret and returns the new one.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	hook, ok := loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid body", http.StatusBadRequest)
+		return
+	}
+	before := *hook
+	if message := req.apply(hook); message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	response := WebhookResponse{}
+	note := ""
+	if req.RotateSecret {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+		hook.Secret, response.Secret = secret, secret
+		note = "secret rotated"
+	}
+
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(hook).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, admin, models.AuditWebhookUpdate, "webhook", hook.ID, before, hook, note)
+		return err
+	})
+	if err != nil {
+		log.Println("DB Update error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	response.Webhook = *hook
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// DeleteWebhook removes a webhook and its delivery log:
+// DELETE /api/admin/webhooks/{id} (admins only)
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	hook, ok := loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	err := storage.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(hook).Error; err != nil {
+			return err
+		}
+		_, err := recordAudit(tx, r, admin, models.AuditWebhookDelete, "webhook", hook.ID, hook, nil, "")
+		return err
+	})
+	if err != nil {
+		log.Println("DB Delete error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🪝 Admin %s deleted webhook %d", admin.Username, hook.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries shows a webhook's delivery log, newest first:
+// GET /api/admin/webhooks/{id}/deliveries?status=&page=&limit= (admins only)
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requireAdmin(w, r); !ok {
+		return
+	}
+	hook, ok := loadWebhook(w, r)
+	if !ok {
+		return
+	}
+
+	query := storage.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := r.URL.Query().Get("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	query = query.Session(&gorm.Session{})
+
+	page, limit := parsePagination(r)
+	response := WebhookDeliveryListResponse{Page: page, Limit: limit, Deliveries: []models.WebhookDelivery{}}
+	if err := query.Count(&response.Total).Error; err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	err := query.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&response.Deliveries).Error
+	if err != nil {
+		log.Println("DB Query error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// RedeliverWebhook sends a logged delivery again as a new delivery:
+// POST /api/admin/webhooks/{id}/deliveries/{delivery_id}/redeliver (admins only)
+func RedeliverWebhook(w http.ResponseWriter, r *http.Request) {
+	admin, ok := requireAdmin(w, r)
+	if !ok {
+		return
+	}
+	hook, ok := loadWebhook(w, r)
+	if !ok {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/webhooks/"), "/")
+	deliveryID, err := strconv.ParseUint(parts[2], 10, 32)
+	if err != nil {
+		http.Error(w, "Invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+	if !hook.IsActive {
+		http.Error(w, "Webhook is disabled, enable it first", http.StatusConflict)
+		return
+	}
+
+	var original models.WebhookDelivery
+	if err := storage.DB.Where("id = ? AND webhook_id = ?", deliveryID, hook.ID).First(&original).Error; err != nil {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	delivery, err := webhook.Redeliver(storage.DB, &original)
+	if err != nil {
+		log.Println("DB Insert error:", err)
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	log.Printf("🪝 Admin %s redelivered delivery %d of webhook %d", admin.Username, original.ID, hook.ID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
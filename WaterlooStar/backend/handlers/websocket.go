@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/realtime"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket timings. The server pings every wsPingInterval and drops clients
+// that have not answered (or sent anything) within wsPongWait.
+const (
+	wsWriteWait    = 10 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsPingInterval = wsPongWait * 9 / 10
+	wsMaxMessage   = 4096
+	wsReplyBuffer  = 16
+	wsTypingEvery  = 3 * time.Second // Typing indicators are forwarded at most this often per thread
+	wsCloseTooSlow = "Too far behind, reconnect with last_event_id"
+)
+
+// Client message types
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsTyping      = "typing"
+	wsPing        = "ping"
+)
+
+// Server message types, besides the event types of the realtime package
+const (
+	wsSubscribed   = "subscribed"
+	wsUnsubscribed = "unsubscribed"
+	wsPong         = "pong"
+	wsError        = "error"
+)
+
+// Tokens are not cookies, so connections from other origins are as safe as
+// the cross-origin API requests the CORS headers already allow
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// WSMessage is the envelope of WebSocket messages in both directions.
+// Clients send {"type": "subscribe", "topic": "post:12", "last_event_id": 0},
+// unsubscribe, typing and ping. The server answers with subscribed,
+// unsubscribed, pong and error, and forwards events with their type, id,
+// topic and data.
+type WSMessage struct {
+	Type        string          `json:"type"`
+	Topic       string          `json:"topic,omitempty"`
+	ID          int64           `json:"id,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	Truncated   bool            `json:"truncated,omitempty"`
+	LastEventID int64           `json:"last_event_id,omitempty"` // subscribe: replay events missed since
+	Viewing     *int            `json:"viewing,omitempty"`       // subscribed: users viewing the thread
+	Message     string          `json:"message,omitempty"`       // error: what went wrong
+}
+
+// TypingIndicator is the data of a typing event. Threads in sections that
+// allow anonymous posting do not say who is typing.
+type TypingIndicator struct {
+	UserID   uint   `json:"user_id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// wsConnections counts open connections per user on this instance
+var wsConnections = struct {
+	sync.Mutex
+	perUser map[uint]int
+}{perUser: make(map[uint]int)}
+
+func acquireWSConnection(userID uint) bool {
+	wsConnections.Lock()
+	defer wsConnections.Unlock()
+	if wsConnections.perUser[userID] >= config.WebSocketMaxConnections {
+		return false
+	}
+	wsConnections.perUser[userID]++
+	return true
+}
+
+func releaseWSConnection(userID uint) {
+	wsConnections.Lock()
+	defer wsConnections.Unlock()
+	wsConnections.perUser[userID]--
+	if wsConnections.perUser[userID] <= 0 {
+		delete(wsConnections.perUser, userID)
+	}
+}
+
+// wsThread is what a connection remembers about a subscribed post topic
+type wsThread struct {
+	anonymous  bool
+	locked     bool
+	lastTyping time.Time
+}
+
+// wsClient is one gateway connection. The handler goroutine reads client
+// messages; writeLoop is the only one writing data messages.
+type wsClient struct {
+	conn         *websocket.Conn
+	viewer       visibility.Viewer
+	username     string
+	shadowbanned bool
+	sub          *realtime.Subscription
+	replies      chan []WSMessage
+	done         chan struct{}
+	topics       map[string]*wsThread // Subscribed topics; nil values for non-post topics
+}
+
+// Gateway is the WebSocket endpoint for live threads: GET /api/ws
+// Browsers pass the JWT as ?access_token=. Post topics also count the user
+// towards the thread's "N viewing" presence and accept typing indicators.
+func Gateway(w http.ResponseWriter, r *http.Request) {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+		return
+	}
+	if realtime.Default == nil {
+		http.Error(w, "Live updates unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if !acquireWSConnection(userClaims.UserID) {
+		http.Error(w, "Too many open connections", http.StatusTooManyRequests)
+		return
+	}
+	defer releaseWSConnection(userClaims.UserID)
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already answered with an error
+		return
+	}
+
+	viewer := visibility.FromRequest(r)
+	sub, _ := realtime.Default.Subscribe(nil, authorFilter(viewer), 0)
+	client := &wsClient{
+		conn:         conn,
+		viewer:       viewer,
+		username:     userClaims.Username,
+		shadowbanned: visibility.Shadowbanned(storage.DB, userClaims.UserID),
+		sub:          sub,
+		replies:      make(chan []WSMessage, wsReplyBuffer),
+		done:         make(chan struct{}),
+		topics:       make(map[string]*wsThread),
+	}
+	go client.writeLoop()
+	client.readLoop()
+
+	close(client.done)
+	realtime.Default.Unsubscribe(sub)
+	for topic, thread := range client.topics {
+		if thread != nil {
+			realtime.Default.Leave(topic, viewer.UserID)
+		}
+	}
+}
+
+func (c *wsClient) readLoop() {
+	c.conn.SetReadLimit(wsMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		var msg WSMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				if !c.reply(WSMessage{Type: wsError, Message: "Invalid message"}) {
+					return
+				}
+				continue
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket read error for user %d: %v", c.viewer.UserID, err)
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var replies []WSMessage
+		switch msg.Type {
+		case wsSubscribe:
+			replies = c.subscribe(msg.Topic, msg.LastEventID)
+		case wsUnsubscribe:
+			replies = c.unsubscribe(msg.Topic)
+		case wsTyping:
+			replies = c.typing(msg.Topic)
+		case wsPing:
+			replies = []WSMessage{{Type: wsPong}}
+		default:
+			replies = []WSMessage{{Type: wsError, Message: "Unknown message type, use subscribe, unsubscribe, typing or ping"}}
+		}
+		if len(replies) > 0 && !c.reply(replies...) {
+			return
+		}
+	}
+}
+
+// reply queues messages for writeLoop. A client that does not read its
+// replies is disconnected rather than buffered without bound.
+func (c *wsClient) reply(messages ...WSMessage) bool {
+	select {
+	case c.replies <- messages:
+		return true
+	default:
+		c.closeWith(websocket.ClosePolicyViolation, wsCloseTooSlow)
+		return false
+	}
+}
+
+// hubTopic maps a client topic name to the hub topic
+func (c *wsClient) hubTopic(name string) string {
+	if name == "notifications" {
+		return realtime.UserTopic(c.viewer.UserID)
+	}
+	return name
+}
+
+func (c *wsClient) subscribe(name string, lastEventID int64) []WSMessage {
+	topic := c.hubTopic(name)
+	if _, ok := c.topics[topic]; ok {
+		return []WSMessage{{Type: wsSubscribed, Topic: topic}}
+	}
+	if len(c.topics) >= maxStreamTopics {
+		return []WSMessage{{Type: wsError, Topic: name, Message: fmt.Sprintf("At most %d topics are allowed", maxStreamTopics)}}
+	}
+	if strings.Contains(name, ",") {
+		return []WSMessage{{Type: wsError, Topic: name, Message: "Subscribe to one topic at a time"}}
+	}
+	if _, _, err := streamTopics(c.viewer, name); err != nil {
+		return []WSMessage{{Type: wsError, Topic: name, Message: err.Error()}}
+	}
+
+	var thread *wsThread
+	if strings.HasPrefix(topic, "post:") {
+		postID, _ := strconv.ParseUint(strings.TrimPrefix(topic, "post:"), 10, 32)
+		var post models.Post
+		if err := storage.DB.First(&post, postID).Error; err != nil {
+			return []WSMessage{{Type: wsError, Topic: name, Message: "Post not found"}}
+		}
+		thread = &wsThread{anonymous: sectionAllowsAnonymous(post.Section), locked: post.IsLocked}
+	}
+
+	c.topics[topic] = thread
+	replay := realtime.Default.AddTopic(c.sub, topic, lastEventID)
+	subscribed := WSMessage{Type: wsSubscribed, Topic: topic}
+	if thread != nil {
+		realtime.Default.Join(topic, c.viewer.UserID)
+		viewing := realtime.Default.Viewing(topic)
+		subscribed.Viewing = &viewing
+	}
+
+	replies := []WSMessage{subscribed}
+	for _, e := range replay {
+		replies = append(replies, eventMessage(e))
+	}
+	return replies
+}
+
+func (c *wsClient) unsubscribe(name string) []WSMessage {
+	topic := c.hubTopic(name)
+	thread, ok := c.topics[topic]
+	if !ok {
+		return []WSMessage{{Type: wsError, Topic: name, Message: "Not subscribed"}}
+	}
+	delete(c.topics, topic)
+	realtime.Default.RemoveTopic(c.sub, topic)
+	if thread != nil {
+		realtime.Default.Leave(topic, c.viewer.UserID)
+	}
+	return []WSMessage{{Type: wsUnsubscribed, Topic: topic}}
+}
+
+// typing forwards a typing indicator to the other viewers of a thread.
+// Indicators of shadowbanned users are silently dropped.
+func (c *wsClient) typing(name string) []WSMessage {
+	topic := c.hubTopic(name)
+	thread := c.topics[topic]
+	if thread == nil {
+		return []WSMessage{{Type: wsError, Topic: name, Message: "Subscribe to a post topic first"}}
+	}
+	if thread.locked || c.shadowbanned || time.Since(thread.lastTyping) < wsTypingEvery {
+		return nil
+	}
+	thread.lastTyping = time.Now()
+
+	if thread.anonymous {
+		realtime.PublishEphemeral(topic, realtime.EventTyping, TypingIndicator{}, 0)
+	} else {
+		indicator := TypingIndicator{UserID: c.viewer.UserID, Username: c.username}
+		realtime.PublishEphemeral(topic, realtime.EventTyping, indicator, c.viewer.UserID)
+	}
+	return nil
+}
+
+func eventMessage(e realtime.Event) WSMessage {
+	return WSMessage{Type: e.Type, ID: e.ID, Topic: e.Topic, Data: e.Data, Truncated: e.Truncated}
+}
+
+// writeLoop sends events, replies and keepalive pings until the connection
+// ends. Every write has a deadline, so a stalled client cannot hold it forever.
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	defer c.conn.Close()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case e, ok := <-c.sub.C:
+			if !ok {
+				// The hub dropped us for falling behind
+				c.closeWith(websocket.CloseTryAgainLater, wsCloseTooSlow)
+				return
+			}
+			if c.write(eventMessage(e)) != nil {
+				return
+			}
+		case messages := <-c.replies:
+			for _, msg := range messages {
+				if c.write(msg) != nil {
+					return
+				}
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *wsClient) write(msg WSMessage) error {
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return c.conn.WriteJSON(msg)
+}
+
+// closeWith sends a close frame; the read loop then ends with the connection.
+// WriteControl may be called concurrently with the other write methods.
+func (c *wsClient) closeWith(code int, reason string) {
+	message := websocket.FormatCloseMessage(code, reason)
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(wsWriteWait))
+	c.conn.Close()
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"log"
+	"time"
+)
+
+const (
+	// UnlinkedAttachmentTTL is how long an upload may stay unused before it
+	// counts as orphaned
+	UnlinkedAttachmentTTL = 24 * time.Hour
+	// AttachmentRetention keeps deleted attachments around so that restoring
+	// their post or comment brings them back
+	AttachmentRetention = 30 * 24 * time.Hour
+)
+
+// CollectAttachments deletes orphaned uploads and purges attachments that have
+// been deleted for longer than AttachmentRetention. A blob is removed from the
+// store only when no remaining attachment row shares it.
+func CollectAttachments(now time.Time) (purged int, err error) {
+	orphans := storage.DB.Where("post_id IS NULL AND comment_id IS NULL AND created_at < ?", now.Add(-UnlinkedAttachmentTTL)).
+		Delete(&models.Attachment{})
+	if orphans.Error != nil {
+		return 0, orphans.Error
+	}
+	if orphans.RowsAffected > 0 {
+		log.Printf("🧹 Attachment GC: deleted %d orphaned uploads", orphans.RowsAffected)
+	}
+
+	var expired []models.Attachment
+	err = storage.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", now.Add(-AttachmentRetention)).
+		Limit(500).Find(&expired).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, attachment := range expired {
+		if err := storage.DB.Unscoped().Delete(&attachment).Error; err != nil {
+			return purged, err
+		}
+		purged++
+
+		var references int64
+		storage.DB.Unscoped().Model(&models.Attachment{}).Where("blob_key = ?", attachment.BlobKey).Count(&references)
+		if references > 0 {
+			continue
+		}
+		if err := storage.Blobs.Delete(attachment.BlobKey); err != nil {
+			log.Printf("Attachment GC: failed to delete blob %s: %v", attachment.BlobKey, err)
+		}
+		if attachment.ThumbnailKey != "" {
+			if err := storage.Blobs.Delete(attachment.ThumbnailKey); err != nil {
+				log.Printf("Attachment GC: failed to delete blob %s: %v", attachment.ThumbnailKey, err)
+			}
+		}
+	}
+	return purged, nil
+}
+
+// StartAttachmentGC runs CollectAttachments every interval in the background
+func StartAttachmentGC(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := CollectAttachments(time.Now())
+			if err != nil {
+				log.Printf("Attachment GC failed: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("🧹 Attachment GC: purged %d deleted attachments", purged)
+			}
+		}
+	}()
+}
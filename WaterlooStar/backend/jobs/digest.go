@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"WaterlooStar/backend/digest"
+	"WaterlooStar/backend/mail"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"log"
+	"time"
+)
+
+// QueueDigests builds the digests that are due and queues them for sending.
+// Each preference is claimed by moving its last_digest_at forward first, so
+// when several instances run this at once every digest is queued once.
+func QueueDigests(now time.Time) (queued int, err error) {
+	for _, frequency := range []string{models.DigestDaily, models.DigestWeekly} {
+		period := digest.Period(frequency)
+		due := "digest = ? AND (last_digest_at IS NULL OR last_digest_at <= ?)"
+
+		var prefs []models.EmailPreference
+		if err := storage.DB.Where(due, frequency, now.Add(-period)).Find(&prefs).Error; err != nil {
+			return queued, err
+		}
+		for i := range prefs {
+			pref := &prefs[i]
+			result := storage.DB.Model(&models.EmailPreference{}).
+				Where("id = ?", pref.ID).Where(due, frequency, now.Add(-period)).
+				UpdateColumn("last_digest_at", now)
+			if result.Error != nil {
+				return queued, result.Error
+			}
+			if result.RowsAffected == 0 {
+				continue
+			}
+
+			// A digest never covers more than one period, even after downtime
+			since := now.Add(-period)
+			if pref.LastDigestAt != nil && pref.LastDigestAt.After(since) {
+				since = *pref.LastDigestAt
+			}
+			sent, err := queueDigest(pref, frequency, since, now)
+			if err != nil {
+				log.Printf("Digest: failed to queue %s digest for user %d: %v", frequency, pref.UserID, err)
+				continue
+			}
+			if sent {
+				queued++
+			}
+		}
+	}
+	return queued, nil
+}
+
+// queueDigest queues one digest. Nothing is sent when there are no posts.
+func queueDigest(pref *models.EmailPreference, frequency string, since, until time.Time) (bool, error) {
+	var user models.User
+	if err := storage.DB.First(&user, pref.UserID).Error; err != nil || !user.IsEmailVerified {
+		return false, err
+	}
+	items, err := digest.Build(storage.DB, pref, since, until)
+	if err != nil || len(items) == 0 {
+		return false, err
+	}
+	msg, err := digest.Message(&user, frequency, items)
+	if err != nil {
+		return false, err
+	}
+	return true, mail.Enqueue(storage.DB, msg)
+}
+
+// StartDigests runs QueueDigests every interval in the background
+func StartDigests(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			queued, err := QueueDigests(time.Now())
+			if err != nil {
+				log.Printf("Digest: failed to queue digests: %v", err)
+				continue
+			}
+			if queued > 0 {
+				log.Printf("📰 Digest: queued %d digest emails", queued)
+			}
+		}
+	}()
+}
@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"WaterlooStar/backend/mention"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/realtime"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/webhook"
+	"log"
+	"time"
+)
+
+// PublishDuePosts publishes scheduled posts whose publish_at has passed and
+// returns their IDs. The single UPDATE ... RETURNING locks each row it changes,
+// so when several backend instances run the scheduler at once every post is
+// still published (and reported) exactly once.
+func PublishDuePosts() ([]uint, error) {
+	var ids []uint
+	err := storage.DB.Raw(`
+		UPDATE posts
+		SET status = ?, published_at = publish_at, updated_at = NOW()
+		WHERE status = ? AND publish_at <= NOW() AND deleted_at IS NULL
+		RETURNING id`,
+		models.PostStatusPublished, models.PostStatusScheduled,
+	).Scan(&ids).Error
+	return ids, err
+}
+
+// StartPostScheduler runs PublishDuePosts every interval in the background
+func StartPostScheduler(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ids, err := PublishDuePosts()
+			if err != nil {
+				log.Printf("Scheduler: failed to publish due posts: %v", err)
+				continue
+			}
+			if len(ids) > 0 {
+				log.Printf("⏰ Scheduler: published %d scheduled posts %v", len(ids), ids)
+				announcePosts(ids)
+			}
+		}
+	}()
+}
+
+// announcePosts notifies the users mentioned in newly published posts and
+// sends the live and webhook post.created events
+func announcePosts(ids []uint) {
+	var posts []models.Post
+	err := storage.DB.Preload("Mentions", "comment_id IS NULL").Find(&posts, ids).Error
+	if err != nil {
+		log.Printf("Scheduler: failed to load published posts: %v", err)
+		return
+	}
+	for i := range posts {
+		if !posts[i].IsVisible() {
+			continue
+		}
+		mention.Notify(storage.DB, posts[i].Mentions, &posts[i], nil)
+		realtime.PublishPost(&posts[i])
+		webhook.PostCreated(&posts[i])
+	}
+}
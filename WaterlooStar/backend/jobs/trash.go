@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize bounds how many rows of each kind one PurgeTrash run removes
+const purgeBatchSize = 100
+
+// TrashRetention is how long deleted content stays restorable
+func TrashRetention() time.Duration {
+	return time.Duration(config.TrashRetentionDays) * 24 * time.Hour
+}
+
+// PurgeTrash permanently removes comments, posts and users that were deleted
+// more than TrashRetention ago. Their attachments are unlinked and left to
+// CollectAttachments, which also removes the blobs.
+func PurgeTrash(now time.Time) (purged int, err error) {
+	cutoff := now.Add(-TrashRetention())
+
+	var commentIDs []uint
+	err = storage.DB.Unscoped().Model(&models.Comment{}).Where("deleted_at < ?", cutoff).
+		Limit(purgeBatchSize).Pluck("id", &commentIDs).Error
+	if err != nil {
+		return 0, err
+	}
+	if len(commentIDs) > 0 {
+		err = storage.DB.Transaction(func(tx *gorm.DB) error {
+			if err := unlinkAttachments(tx.Where("comment_id IN ?", commentIDs), now); err != nil {
+				return err
+			}
+			if err := tx.Where("comment_id IN ?", commentIDs).Delete(&models.Mention{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Where("id IN ?", commentIDs).Delete(&models.Comment{}).Error
+		})
+		if err != nil {
+			return 0, err
+		}
+		purged += len(commentIDs)
+	}
+
+	var postIDs []uint
+	err = storage.DB.Unscoped().Model(&models.Post{}).Where("deleted_at < ?", cutoff).
+		Limit(purgeBatchSize).Pluck("id", &postIDs).Error
+	if err != nil {
+		return purged, err
+	}
+	for _, postID := range postIDs {
+		if err := storage.DB.Transaction(func(tx *gorm.DB) error { return purgePost(tx, postID, now) }); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+
+	// Users are only purged once nothing they wrote is left
+	var userIDs []uint
+	err = storage.DB.Unscoped().Model(&models.User{}).
+		Where("deleted_at < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM posts WHERE posts.author_id = users.id)").
+		Where("NOT EXISTS (SELECT 1 FROM comments WHERE comments.author_id = users.id)").
+		Limit(purgeBatchSize).Pluck("id", &userIDs).Error
+	if err != nil {
+		return purged, err
+	}
+	for _, userID := range userIDs {
+		if err := storage.DB.Transaction(func(tx *gorm.DB) error { return purgeUser(tx, userID) }); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// unlinkAttachments detaches the attachments matched by query from their post
+// or comment and marks them deleted so that CollectAttachments picks them up
+func unlinkAttachments(query *gorm.DB, now time.Time) error {
+	return query.Unscoped().Model(&models.Attachment{}).UpdateColumns(map[string]interface{}{
+		"post_id":    nil,
+		"comment_id": nil,
+		"deleted_at": gorm.Expr("COALESCE(deleted_at, ?)", now),
+	}).Error
+}
+
+// purgePost removes a post and everything that belongs to it
+func purgePost(tx *gorm.DB, postID uint, now time.Time) error {
+	commentIDs := tx.Unscoped().Model(&models.Comment{}).Select("id").Where("post_id = ?", postID)
+	if err := unlinkAttachments(tx.Where("post_id = ? OR comment_id IN (?)", postID, commentIDs), now); err != nil {
+		return err
+	}
+
+	pollIDs := tx.Model(&models.Poll{}).Select("id").Where("post_id = ?", postID)
+	if err := tx.Where("poll_id IN (?)", pollIDs).Delete(&models.PollVote{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("poll_id IN (?)", pollIDs).Delete(&models.PollOption{}).Error; err != nil {
+		return err
+	}
+	// Mentions go first, those in comments also refer to the comment
+	for _, model := range []interface{}{&models.Mention{}, &models.Poll{}, &models.Bookmark{}, &models.ThreadSubscription{}, &models.ThreadPseudonym{}, &models.PostLike{}, &models.Comment{}} {
+		if err := tx.Unscoped().Where("post_id = ?", postID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Delete(&models.Post{}, postID).Error
+}
+
+// purgeUser removes a user and everything personal that refers to them:
+// likes, bookmarks, votes, follows both ways, blocks and mutes both ways,
+// notifications they received or caused, settings, feeds, subscriptions,
+// mentions of them and their conversation memberships. Messages they sent
+// stay with the other members, and moderation records keep their ID.
+func purgeUser(tx *gorm.DB, userID uint) error {
+	// The posts they liked are recounted once the likes are gone
+	var likedPostIDs []uint
+	if err := tx.Unscoped().Model(&models.PostLike{}).Where("user_id = ?", userID).Distinct().Pluck("post_id", &likedPostIDs).Error; err != nil {
+		return err
+	}
+	for _, model := range []interface{}{
+		&models.PostLike{}, &models.Bookmark{}, &models.PollVote{}, &models.Follow{},
+		&models.FeedCache{}, &models.FeedEntry{}, &models.ThreadSubscription{},
+		&models.NotificationPreference{}, &models.EmailPreference{}, &models.Mention{},
+		&models.ConversationParticipant{}, &models.ThreadPseudonym{},
+	} {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if len(likedPostIDs) > 0 {
+		err := tx.Exec("UPDATE posts SET likes = (SELECT COUNT(*) FROM post_likes WHERE post_id = posts.id AND deleted_at IS NULL) WHERE id IN ?",
+			likedPostIDs).Error
+		if err != nil {
+			return err
+		}
+	}
+	if err := tx.Where("kind = ? AND followed_id = ?", models.FollowUser, userID).Delete(&models.Follow{}).Error; err != nil {
+		return err
+	}
+	for _, pair := range []struct {
+		model  interface{}
+		column string // Refers to the other user of the row
+	}{
+		{&models.UserBlock{}, "blocked_id"},
+		{&models.UserMute{}, "muted_id"},
+		{&models.Notification{}, "actor_id"},
+	} {
+		if err := tx.Unscoped().Where("user_id = ? OR "+pair.column+" = ?", userID, userID).Delete(pair.model).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Unscoped().Delete(&models.User{}, userID).Error
+}
+
+// StartTrashPurge runs PurgeTrash every interval in the background
+func StartTrashPurge(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			purged, err := PurgeTrash(time.Now())
+			if err != nil {
+				log.Printf("Trash purge failed: %v", err)
+				continue
+			}
+			if purged > 0 {
+				log.Printf("🗑️ Trash purge: removed %d expired items", purged)
+			}
+		}
+	}()
+}
@@ -0,0 +1,86 @@
+// Package mail sends email through the configured SMTP server. Account mail
+// such as verification is sent directly; notification mail goes through the
+// durable queue so that SMTP hiccups are retried.
+package mail
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/queue"
+	"encoding/json"
+	"fmt"
+	"log"
+	"mime"
+	"net/smtp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Queue is the job queue for outgoing email
+const Queue = "email"
+
+// Message is one HTML email. Headers are added as is, e.g. List-Unsubscribe.
+type Message struct {
+	To      string            `json:"to"`
+	Subject string            `json:"subject"`
+	HTML    string            `json:"html"`
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func init() {
+	queue.Register(Queue, func(payload []byte) error {
+		var msg Message
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			return err
+		}
+		return Send(msg)
+	})
+}
+
+// Configured reports whether SMTP credentials are set
+func Configured() bool {
+	return config.SMTPEmail != "" && config.SMTPPassword != ""
+}
+
+// Send delivers msg now. Without SMTP credentials it logs and returns nil, so
+// features that send mail keep working in development.
+func Send(msg Message) error {
+	if !Configured() {
+		log.Printf("SMTP credentials not configured, skipping email %q to %s", msg.Subject, msg.To)
+		return nil
+	}
+
+	auth := smtp.PlainAuth("", config.SMTPEmail, config.SMTPPassword, config.SMTPHost)
+	return smtp.SendMail(config.SMTPHost+":"+config.SMTPPort, auth, config.SMTPEmail, []string{msg.To}, msg.bytes())
+}
+
+// Enqueue queues msg for sending by the queue worker
+func Enqueue(db *gorm.DB, msg Message) error {
+	return queue.Enqueue(db, Queue, msg)
+}
+
+// bytes formats msg as an RFC 5322 message
+func (msg Message) bytes() []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", config.SMTPEmail)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		// Header values come from our own code, but never let one break the header block
+		value := strings.NewReplacer("\r", "", "\n", "").Replace(msg.Headers[name])
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+
+	b.WriteString("\r\n")
+	b.WriteString(msg.HTML)
+	return []byte(b.String())
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"WaterlooStar/backend/config"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// What an unsubscribe link turns off
+const (
+	UnsubscribeReplies = "replies"
+	UnsubscribeDigest  = "digest"
+)
+
+var ErrInvalidToken = errors.New("invalid unsubscribe link")
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(config.EmailSigningKey))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// UnsubscribeToken signs "userID:kind" so that the link works without
+// logging in but cannot be forged for other users
+func UnsubscribeToken(userID uint, kind string) string {
+	payload := fmt.Sprintf("%d:%s", userID, kind)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + sign(payload)
+}
+
+// ParseUnsubscribeToken checks a token from UnsubscribeToken
+func ParseUnsubscribeToken(token string) (userID uint, kind string, err error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, "", ErrInvalidToken
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(signature), []byte(sign(payload))) {
+		return 0, "", ErrInvalidToken
+	}
+	id, kind, ok := strings.Cut(payload, ":")
+	if !ok || (kind != UnsubscribeReplies && kind != UnsubscribeDigest) {
+		return 0, "", ErrInvalidToken
+	}
+	n, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, "", ErrInvalidToken
+	}
+	return uint(n), kind, nil
+}
+
+// UnsubscribeURL is the one-click unsubscribe link for a user and kind
+func UnsubscribeURL(userID uint, kind string) string {
+	return config.APIURL + "/api/email/unsubscribe?token=" + url.QueryEscape(UnsubscribeToken(userID, kind))
+}
+
+// UnsubscribeHeaders are the RFC 8058 headers that let mail clients
+// unsubscribe with a single POST to the link
+func UnsubscribeHeaders(userID uint, kind string) map[string]string {
+	return map[string]string{
+		"List-Unsubscribe":      "<" + UnsubscribeURL(userID, kind) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+	}
+}
@@ -0,0 +1,75 @@
+package mail
+
+import (
+	"WaterlooStar/backend/config"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUnsubscribeTokenRoundTrip(t *testing.T) {
+	tests := []struct {
+		userID uint
+		kind   string
+	}{
+		{1, UnsubscribeReplies},
+		{42, UnsubscribeDigest},
+		{4294967295, UnsubscribeDigest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			userID, kind, err := ParseUnsubscribeToken(UnsubscribeToken(tt.userID, tt.kind))
+			if err != nil || userID != tt.userID || kind != tt.kind {
+				t.Errorf("ParseUnsubscribeToken = %d, %q, %v; want %d, %q, nil", userID, kind, err, tt.userID, tt.kind)
+			}
+		})
+	}
+}
+
+// forge signs an arbitrary payload with the current key
+func forge(payload string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + sign(payload)
+}
+
+func TestParseUnsubscribeTokenRejects(t *testing.T) {
+	valid := UnsubscribeToken(7, UnsubscribeReplies)
+	encoded, signature, _ := strings.Cut(valid, ".")
+	otherUser, _, _ := strings.Cut(UnsubscribeToken(8, UnsubscribeReplies), ".")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"no signature", encoded},
+		{"empty signature", encoded + "."},
+		{"truncated signature", encoded + "." + signature[:len(signature)-1]},
+		{"other user's payload", otherUser + "." + signature},
+		{"payload not base64", "!!!." + signature},
+		{"extra part", valid + ".extra"},
+		{"unknown kind", forge("7:everything")},
+		{"no kind", forge("7")},
+		{"negative ID", forge("-7:replies")},
+		{"ID out of range", forge("4294967296:replies")},
+		{"not a number", forge("seven:replies")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ParseUnsubscribeToken(tt.token); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("ParseUnsubscribeToken(%q) error = %v, want ErrInvalidToken", tt.token, err)
+			}
+		})
+	}
+}
+
+func TestParseUnsubscribeTokenOtherKey(t *testing.T) {
+	token := UnsubscribeToken(7, UnsubscribeDigest)
+	key := config.EmailSigningKey
+	config.EmailSigningKey = key + "-rotated"
+	defer func() { config.EmailSigningKey = key }()
+
+	if _, _, err := ParseUnsubscribeToken(token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token signed with another key: error = %v, want ErrInvalidToken", err)
+	}
+}
@@ -10,8 +10,12 @@ import (
 	"strings"
 	"time"
 
+	"WaterlooStar/backend/config"
 	"WaterlooStar/backend/handlers"
+	"WaterlooStar/backend/jobs"
 	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/queue"
+	"WaterlooStar/backend/realtime"
 	"WaterlooStar/backend/storage"
 )
 
@@ -24,12 +28,27 @@ func main() {
 	storage.InitDB(dsn)
 	storage.Migrate()
 
+	uploadDir := os.Getenv("UPLOAD_DIR")
+	if uploadDir == "" {
+		uploadDir = "uploads"
+	}
+	storage.InitBlobStore(uploadDir)
+	storage.InitRateLimitStore(config.RateLimitStore)
+	realtime.Init(config.RealtimeBackend, dsn)
+
+	// Background jobs
+	jobs.StartPostScheduler(time.Minute)
+	jobs.StartAttachmentGC(time.Hour)
+	jobs.StartTrashPurge(time.Hour)
+	jobs.StartDigests(15 * time.Minute)
+	queue.Start(30 * time.Second)
+
 	// Simple CORS and Logging middleware
 	corsHandler := func(next http.HandlerFunc) http.HandlerFunc {
-		return func(w http.ResponseWriter, r *http.Request) {
+		return middleware.RequestIDMiddleware(func(w http.ResponseWriter, r *http.Request) {
 			// Request logging
 			log.Printf("🌐 [%s] %s %s", r.RemoteAddr, r.Method, r.URL.Path)
-			if r.Method == "POST" || r.Method == "PUT" {
+			if (r.Method == "POST" || r.Method == "PUT") && !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
 				body, _ := io.ReadAll(r.Body)
 				r.Body = io.NopCloser(strings.NewReader(string(body)))
 				log.Printf("📝 Request Body: %s", string(body))
@@ -38,7 +57,8 @@ func main() {
 			// CORS
 			log.Printf("🔄 CORS: Processing %s request to %s", r.Method, r.URL.Path)
 			w.Header().Set("Access-Control-Allow-Origin", "*")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, Last-Event-ID")
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, RateLimit-Policy, Retry-After")
 			w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
 			if r.Method == http.MethodOptions {
 				log.Printf("✅ CORS: OPTIONS preflight handled - Status: 200")
@@ -50,7 +70,7 @@ func main() {
 			log.Printf("🚀 Calling handler for %s %s", r.Method, r.URL.Path)
 			next(w, r)
 			log.Printf("✅ Handler completed for %s %s", r.Method, r.URL.Path)
-		}
+		})
 	}
 
 	// Test endpoint
@@ -66,7 +86,7 @@ func main() {
 	// Authentication endpoints
 	http.HandleFunc("/api/auth/register", corsHandler(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			handlers.Register(w, r)
+			middleware.RateLimitMiddleware(config.RouteAuthRegister, handlers.Register)(w, r)
 			return
 		}
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -74,7 +94,7 @@ func main() {
 
 	http.HandleFunc("/api/auth/login", corsHandler(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodPost {
-			handlers.Login(w, r)
+			middleware.RateLimitMiddleware(config.RouteAuthLogin, handlers.Login)(w, r)
 			return
 		}
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -97,7 +117,7 @@ func main() {
 		}
 		if r.Method == http.MethodPost {
 			// Require auth for POST (only authenticated users can create)
-			middleware.AuthMiddleware(handlers.CreatePost)(w, r)
+			middleware.AuthMiddleware(middleware.RateLimitMiddleware(config.RoutePostCreate, handlers.CreatePost))(w, r)
 			return
 		}
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -111,7 +131,7 @@ func main() {
 		if len(parts) >= 2 && parts[1] == "like" {
 			// Handle post likes: /api/posts/{id}/like
 			if r.Method == http.MethodPost {
-				middleware.AuthMiddleware(handlers.TogglePostLike)(w, r)
+				middleware.AuthMiddleware(middleware.RateLimitMiddleware(config.RoutePostLike, handlers.TogglePostLike))(w, r)
 				return
 			}
 			if r.Method == http.MethodGet {
@@ -128,17 +148,535 @@ func main() {
 			}
 
 			if r.Method == http.MethodGet {
-				handlers.GetComments(w, r)
+				middleware.OptionalAuthMiddleware(handlers.GetComments)(w, r)
 				return
 			}
 			if r.Method == http.MethodPost {
-				middleware.AuthMiddleware(handlers.CreateComment)(w, r)
+				middleware.AuthMiddleware(middleware.RateLimitMiddleware(config.RouteCommentCreate, handlers.CreateComment))(w, r)
+				return
+			}
+		} else if len(parts) >= 3 && parts[1] == "poll" && parts[2] == "vote" {
+			// Handle poll votes: /api/posts/{id}/poll/vote
+			if r.Method == http.MethodPost {
+				middleware.AuthMiddleware(handlers.VotePoll)(w, r)
+				return
+			}
+		} else if len(parts) >= 2 && parts[1] == "bookmark" {
+			// Handle bookmarks: /api/posts/{id}/bookmark
+			if r.Method == http.MethodPut {
+				middleware.AuthMiddleware(handlers.BookmarkPost)(w, r)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				middleware.AuthMiddleware(handlers.UnbookmarkPost)(w, r)
+				return
+			}
+		} else if len(parts) >= 2 && parts[1] == "subscription" {
+			// Handle thread subscriptions: /api/posts/{id}/subscription
+			if r.Method == http.MethodPut {
+				middleware.AuthMiddleware(handlers.SubscribePost)(w, r)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				middleware.AuthMiddleware(handlers.UnsubscribePost)(w, r)
+				return
+			}
+		} else if len(parts) >= 2 && r.Method == http.MethodPut {
+			// Moderator-only flags: /api/posts/{id}/pin, /lock, /feature
+			switch parts[1] {
+			case "pin":
+				middleware.AuthMiddleware(handlers.SetPostPin)(w, r)
+				return
+			case "lock":
+				middleware.AuthMiddleware(handlers.SetPostLock)(w, r)
+				return
+			case "feature":
+				middleware.AuthMiddleware(handlers.SetPostFeature)(w, r)
+				return
+			}
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+
+	// Section endpoints
+	http.HandleFunc("/api/sections", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			handlers.GetSections(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/sections/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.UpdateSection)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Attachment endpoints
+	http.HandleFunc("/api/attachments", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.UploadAttachment)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/attachments/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/attachments/")
+		parts := strings.Split(path, "/")
+
+		// Downloads check who may see the attachment; <img> tags can pass
+		// the token as ?access_token=
+		if len(parts) == 2 && parts[1] == "thumbnail" && r.Method == http.MethodGet {
+			middleware.QueryTokenMiddleware(middleware.OptionalAuthMiddleware(handlers.GetAttachmentThumbnail))(w, r)
+			return
+		}
+		if len(parts) == 1 {
+			if r.Method == http.MethodGet {
+				middleware.QueryTokenMiddleware(middleware.OptionalAuthMiddleware(handlers.GetAttachment))(w, r)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				middleware.AuthMiddleware(handlers.DeleteAttachment)(w, r)
 				return
 			}
 		}
 		http.Error(w, "Not found", http.StatusNotFound)
 	}))
 
+	// Reporting and moderation queue endpoints
+	http.HandleFunc("/api/reports", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.CreateReport)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/mod/reports", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetModReports)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/mod/reports/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		// Resolve a report: /api/mod/reports/{id}/resolve
+		if strings.HasSuffix(r.URL.Path, "/resolve") && r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.ResolveReport)(w, r)
+			return
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+
+	http.HandleFunc("/api/mod/bans", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetBans)(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.CreateBan)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/mod/bans/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			middleware.AuthMiddleware(handlers.LiftBan)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/mod/users/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/mod/users/"), "/")
+		if len(parts) == 2 && parts[1] == "shadowban" && r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.SetShadowban)(w, r)
+			return
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+
+	http.HandleFunc("/api/mod/shadowbans", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetShadowbans)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/mod/trash", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetTrash)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/mod/trash/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.RestoreTrash)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/mod/filters", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetContentFilters)(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.CreateContentFilter)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/mod/filters/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			middleware.AuthMiddleware(handlers.DeleteContentFilter)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Admin endpoints
+	http.HandleFunc("/api/admin/audit", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetAuditLog)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/admin/users/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/users/"), "/")
+		if len(parts) == 2 && parts[1] == "role" && r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.SetUserRole)(w, r)
+			return
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+
+	http.HandleFunc("/api/admin/webhooks", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetWebhooks)(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.CreateWebhook)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/admin/webhooks/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/admin/webhooks/"), "/")
+		if len(parts) == 1 && r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.UpdateWebhook)(w, r)
+			return
+		}
+		if len(parts) == 1 && r.Method == http.MethodDelete {
+			middleware.AuthMiddleware(handlers.DeleteWebhook)(w, r)
+			return
+		}
+		if len(parts) == 2 && parts[1] == "deliveries" && r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetWebhookDeliveries)(w, r)
+			return
+		}
+		if len(parts) == 4 && parts[1] == "deliveries" && parts[3] == "redeliver" && r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.RedeliverWebhook)(w, r)
+			return
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+
+	// Current user endpoints
+	http.HandleFunc("/api/me/bookmarks", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMyBookmarks)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/subscriptions", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMySubscriptions)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/bans", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMyBans)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/blocks", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMyBlocks)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/blocks/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.BlockUser)(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			middleware.AuthMiddleware(handlers.UnblockUser)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/mutes", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMyMutes)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/mutes/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.MuteUser)(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			middleware.AuthMiddleware(handlers.UnmuteUser)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/follows", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMyFollows)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Follow users, sections and tags: /api/me/follows/{users|sections|tags}/{target}
+	http.HandleFunc("/api/me/follows/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.Follow)(w, r)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			middleware.AuthMiddleware(handlers.Unfollow)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/feed", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetFeed)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/users/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.OptionalAuthMiddleware(handlers.GetUserProfile)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// RSS and Atom for feed readers, no login: /feeds/{section}.rss,
+	// /feeds/tags/{tag}.atom, /feeds/users/{id}.rss
+	http.HandleFunc("/feeds/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			handlers.GetSyndicationFeed(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/notifications", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMyNotifications)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/notifications/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/me/notifications/")
+		parts := strings.Split(path, "/")
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if path == "read-all" {
+			middleware.AuthMiddleware(handlers.MarkAllNotificationsRead)(w, r)
+			return
+		}
+		if len(parts) == 2 && parts[1] == "read" {
+			middleware.AuthMiddleware(handlers.MarkNotificationRead)(w, r)
+			return
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+
+	http.HandleFunc("/api/me/mentions", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMyMentions)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/email-preferences", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetEmailPreferences)(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.UpdateEmailPreferences)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Signed links from emails, no login needed: /api/email/unsubscribe?token=
+	http.HandleFunc("/api/email/unsubscribe", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodPost {
+			handlers.Unsubscribe(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/notification-preferences", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetNotificationPreferences)(w, r)
+			return
+		}
+		if r.Method == http.MethodPut {
+			middleware.AuthMiddleware(handlers.UpdateNotificationPreferences)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/drafts", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetMyDrafts)(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(handlers.CreateDraft)(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/me/drafts/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/me/drafts/")
+		parts := strings.Split(path, "/")
+
+		if len(parts) >= 2 && parts[1] == "publish" {
+			// Publish or schedule: /api/me/drafts/{id}/publish
+			if r.Method == http.MethodPost {
+				middleware.AuthMiddleware(middleware.RateLimitMiddleware(config.RoutePostCreate, handlers.PublishDraft))(w, r)
+				return
+			}
+		} else if len(parts) == 1 {
+			// Autosave and discard: /api/me/drafts/{id}
+			if r.Method == http.MethodPut {
+				middleware.AuthMiddleware(handlers.AutosaveDraft)(w, r)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				middleware.AuthMiddleware(handlers.DeleteDraft)(w, r)
+				return
+			}
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+
+	// Direct messages
+	http.HandleFunc("/api/conversations", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.AuthMiddleware(handlers.GetConversations)(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			middleware.AuthMiddleware(middleware.RateLimitMiddleware(config.RouteMessageCreate, handlers.CreateConversation))(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	http.HandleFunc("/api/conversations/", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/api/conversations/")
+		parts := strings.Split(path, "/")
+
+		if len(parts) >= 2 && parts[1] == "messages" {
+			// Messages: /api/conversations/{id}/messages
+			if r.Method == http.MethodGet {
+				middleware.AuthMiddleware(handlers.GetMessages)(w, r)
+				return
+			}
+			if r.Method == http.MethodPost {
+				middleware.AuthMiddleware(middleware.RateLimitMiddleware(config.RouteMessageCreate, handlers.SendMessage))(w, r)
+				return
+			}
+		} else if len(parts) >= 2 && parts[1] == "read" {
+			// Read receipts: /api/conversations/{id}/read
+			if r.Method == http.MethodPut {
+				middleware.AuthMiddleware(handlers.MarkConversationRead)(w, r)
+				return
+			}
+		} else if len(parts) >= 2 && parts[1] == "mute" {
+			// Per-conversation mute: /api/conversations/{id}/mute
+			if r.Method == http.MethodPut {
+				middleware.AuthMiddleware(handlers.MuteConversation)(w, r)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				middleware.AuthMiddleware(handlers.UnmuteConversation)(w, r)
+				return
+			}
+		}
+		http.Error(w, "Not found", http.StatusNotFound)
+	}))
+
+	// Live updates as Server-Sent Events: /api/stream?topics=
+	http.HandleFunc("/api/stream", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.QueryTokenMiddleware(middleware.OptionalAuthMiddleware(handlers.Stream))(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
+	// Live threads over WebSocket: /api/ws
+	http.HandleFunc("/api/ws", corsHandler(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			middleware.QueryTokenMiddleware(middleware.AuthMiddleware(handlers.Gateway))(w, r)
+			return
+		}
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}))
+
 	log.Println("Backend running on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
@@ -0,0 +1,214 @@
+// Package mention finds @username mentions in posts and comments, stores the
+// ones that resolve to users and notifies the users mentioned.
+package mention
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/notify"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// maxMentions caps how many users one post or comment can mention, so a
+// single comment cannot notify the whole site
+const maxMentions = 20
+
+// Span is a candidate mention found in content, before it is resolved
+type Span struct {
+	Username string
+	Start    int // Offsets in code points, see models.Mention
+	End      int
+}
+
+// isNameByte reports whether b can be part of a mentionable username
+func isNameByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_' || b == '.' || b == '-'
+}
+
+// Parse finds the @username spans in content. An @ only starts a mention at
+// the start of the text or after a character that cannot be part of a name,
+// so email addresses are not mentions. Trailing dots and dashes are treated
+// as punctuation: "thanks @alice." mentions alice.
+func Parse(content string) []Span {
+	var spans []Span
+	seen := make(map[string]bool)
+	runes := 0 // Code points before i
+	for i := 0; i < len(content); {
+		if content[i] != '@' || (i > 0 && (isNameByte(content[i-1]) || content[i-1] == '@')) {
+			_, size := utf8.DecodeRuneInString(content[i:])
+			i += size
+			runes++
+			continue
+		}
+
+		end := i + 1
+		for end < len(content) && isNameByte(content[end]) {
+			end++
+		}
+		for end > i+1 && (content[end-1] == '.' || content[end-1] == '-') {
+			end--
+		}
+		if end == i+1 {
+			i++
+			runes++
+			continue
+		}
+
+		// Usernames are ASCII here, so bytes and code points agree within the span
+		username := content[i+1 : end]
+		spans = append(spans, Span{Username: username, Start: runes, End: runes + end - i})
+		seen[strings.ToLower(username)] = true
+		if len(seen) > maxMentions {
+			return spans[:len(spans)-1]
+		}
+		runes += end - i
+		i = end
+	}
+	return spans
+}
+
+// Resolve matches spans against existing users. Exact matches win; otherwise
+// a case-insensitive match is used when it is unambiguous. Users who blocked
+// authorID or were blocked by them cannot be mentioned; pass 0 to skip that
+// check for anonymous content, where it would reveal the author.
+func Resolve(db *gorm.DB, spans []Span, authorID uint) ([]models.Mention, error) {
+	if len(spans) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(spans))
+	for _, span := range spans {
+		names = append(names, strings.ToLower(span.Username))
+	}
+	var users []models.User
+	if err := db.Select("id", "username").Where("LOWER(username) IN ?", names).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	if authorID != 0 {
+		// Pluck replaces the slice, so each direction gets its own
+		var blocked, blockers []uint
+		err := db.Model(&models.UserBlock{}).Where("user_id = ?", authorID).Pluck("blocked_id", &blocked).Error
+		if err == nil {
+			err = db.Model(&models.UserBlock{}).Where("blocked_id = ?", authorID).Pluck("user_id", &blockers).Error
+		}
+		if err != nil {
+			return nil, err
+		}
+		users = withoutUsers(users, append(blocked, blockers...))
+	}
+	return match(spans, users), nil
+}
+
+// match pairs spans with users. Exact matches win; otherwise a
+// case-insensitive match is used when it is unambiguous.
+func match(spans []Span, users []models.User) []models.Mention {
+	exact := make(map[string]models.User, len(users))
+	folded := make(map[string][]models.User, len(users))
+	for _, user := range users {
+		exact[user.Username] = user
+		key := strings.ToLower(user.Username)
+		folded[key] = append(folded[key], user)
+	}
+
+	var mentions []models.Mention
+	for _, span := range spans {
+		user, ok := exact[span.Username]
+		if !ok {
+			candidates := folded[strings.ToLower(span.Username)]
+			if len(candidates) != 1 {
+				continue
+			}
+			user = candidates[0]
+		}
+		mentions = append(mentions, models.Mention{
+			UserID:   user.ID,
+			Username: user.Username,
+			Start:    span.Start,
+			End:      span.End,
+		})
+	}
+	return mentions
+}
+
+// withoutUsers drops the users whose IDs are in ids
+func withoutUsers(users []models.User, ids []uint) []models.User {
+	drop := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		drop[id] = true
+	}
+	kept := users[:0]
+	for _, user := range users {
+		if !drop[user.ID] {
+			kept = append(kept, user)
+		}
+	}
+	return kept
+}
+
+// Save replaces the stored mentions of a post (commentID nil) or of one of
+// its comments with the mentions in content and returns them. Call it in the
+// same transaction that saves the content.
+func Save(tx *gorm.DB, postID uint, commentID *uint, authorID uint, anonymous bool, content string) ([]models.Mention, error) {
+	query := tx.Where("post_id = ?", postID)
+	if commentID == nil {
+		query = query.Where("comment_id IS NULL")
+	} else {
+		query = query.Where("comment_id = ?", *commentID)
+	}
+	if err := query.Delete(&models.Mention{}).Error; err != nil {
+		return nil, err
+	}
+
+	blockCheckID := authorID
+	if anonymous {
+		blockCheckID = 0
+	}
+	mentions, err := Resolve(tx, Parse(content), blockCheckID)
+	if err != nil || len(mentions) == 0 {
+		return nil, err
+	}
+	for i := range mentions {
+		mentions[i].PostID = postID
+		mentions[i].CommentID = commentID
+		mentions[i].AuthorID = authorID
+	}
+	return mentions, tx.Create(&mentions).Error
+}
+
+// Notify tells each mentioned user once that they were mentioned. Call it
+// when the content becomes visible, not when a draft is saved. Blocks, mutes
+// and notification preferences are applied by notify.Send. It returns the
+// users who got a notification.
+func Notify(db *gorm.DB, mentions []models.Mention, post *models.Post, comment *models.Comment) map[uint]bool {
+	event := notify.Event{
+		Type:      models.NotifyMention,
+		ActorID:   post.AuthorID,
+		ActorName: post.Author,
+		PostID:    &post.ID,
+		Subject:   post.Title,
+		Body:      notify.Excerpt(post.Content, 140),
+		Anonymous: post.IsAnonymous,
+	}
+	if comment != nil {
+		event.ActorID, event.ActorName = comment.AuthorID, comment.Author
+		event.Anonymous = comment.IsAnonymous
+		event.CommentID = &comment.ID
+		event.Body = notify.Excerpt(comment.Content, 140)
+	}
+
+	seen := make(map[uint]bool, len(mentions))
+	notified := make(map[uint]bool, len(mentions))
+	for _, m := range mentions {
+		if seen[m.UserID] {
+			continue
+		}
+		seen[m.UserID] = true
+		event.UserID = m.UserID
+		if notify.Send(db, event) {
+			notified[m.UserID] = true
+		}
+	}
+	return notified
+}
@@ -0,0 +1,134 @@
+package mention
+
+import (
+	"WaterlooStar/backend/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Span
+	}{
+		{"empty", "", nil},
+		{"start of text", "@alice hi", []Span{{"alice", 0, 6}}},
+		{"after space", "hi @bob", []Span{{"bob", 3, 7}}},
+		{"email address", "mail alice@example.com", nil},
+		{"double at", "@@alice", nil},
+		{"bare at", "@ alice", nil},
+		{"trailing dot", "thanks @alice.", []Span{{"alice", 7, 13}}},
+		{"trailing dots and dashes", "@alice.-.", []Span{{"alice", 0, 6}}},
+		{"inner dot", "@alice.smith!", []Span{{"alice.smith", 0, 12}}},
+		{"comma", "@alice, @bob", []Span{{"alice", 0, 6}, {"bob", 8, 12}}},
+		{"parenthesized", "(@alice)", []Span{{"alice", 1, 7}}},
+		{"code points before", "héllo @alice", []Span{{"alice", 6, 12}}},
+		{"astral code points before", "🎉🎉 @bob", []Span{{"bob", 3, 7}}},
+		{"code points between", "@a 日本 @b", []Span{{"a", 0, 2}, {"b", 6, 8}}},
+		{"after non-ASCII letter", "日@alice", []Span{{"alice", 1, 7}}},
+		{"repeated", "@alice @alice", []Span{{"alice", 0, 6}, {"alice", 7, 13}}},
+		{"case variants kept", "@Alice @alice", []Span{{"Alice", 0, 6}, {"alice", 7, 13}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCap(t *testing.T) {
+	var names []string
+	for i := 0; i < maxMentions+5; i++ {
+		names = append(names, "@user"+string(rune('a'+i)))
+	}
+
+	tests := []struct {
+		name      string
+		content   string
+		wantSpans int
+	}{
+		{"at the cap", strings.Join(names[:maxMentions], " "), maxMentions},
+		{"over the cap", strings.Join(names, " "), maxMentions},
+		// Repeats and case variants of the same name do not count again
+		{"repeats", strings.Join(names[:maxMentions], " ") + " @usera @USERA", maxMentions + 2},
+		{"repeats then new", strings.Join(names[:maxMentions], " ") + " @USERA " + names[maxMentions], maxMentions + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.content); len(got) != tt.wantSpans {
+				t.Errorf("Parse found %d spans, want %d", len(got), tt.wantSpans)
+			}
+		})
+	}
+}
+
+func TestMatch(t *testing.T) {
+	alice := models.User{ID: 1, Username: "alice"}
+	aliceUpper := models.User{ID: 2, Username: "Alice"}
+	bob := models.User{ID: 3, Username: "Bob"}
+
+	tests := []struct {
+		name  string
+		spans []Span
+		users []models.User
+		want  []uint // Mentioned user IDs in order
+	}{
+		{"exact", []Span{{"alice", 0, 6}}, []models.User{alice}, []uint{1}},
+		{"case-insensitive", []Span{{"bob", 0, 4}}, []models.User{bob}, []uint{3}},
+		{"exact wins over ambiguous", []Span{{"Alice", 0, 6}}, []models.User{alice, aliceUpper}, []uint{2}},
+		{"ambiguous skipped", []Span{{"ALICE", 0, 6}}, []models.User{alice, aliceUpper}, nil},
+		{"unknown skipped", []Span{{"carol", 0, 6}, {"bob", 7, 11}}, []models.User{bob}, []uint{3}},
+		{"no users", []Span{{"alice", 0, 6}}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []uint
+			for _, m := range match(tt.spans, tt.users) {
+				got = append(got, m.UserID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("match mentioned %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchKeepsSpans(t *testing.T) {
+	got := match([]Span{{"bob", 3, 7}}, []models.User{{ID: 3, Username: "Bob"}})
+	want := []models.Mention{{UserID: 3, Username: "Bob", Start: 3, End: 7}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("match = %+v, want %+v", got, want)
+	}
+}
+
+func TestWithoutUsers(t *testing.T) {
+	users := func(ids ...uint) []models.User {
+		var list []models.User
+		for _, id := range ids {
+			list = append(list, models.User{ID: id})
+		}
+		return list
+	}
+	tests := []struct {
+		name  string
+		users []models.User
+		ids   []uint
+		want  []models.User
+	}{
+		{"none dropped", users(1, 2), nil, users(1, 2)},
+		{"some dropped", users(1, 2, 3), []uint{2}, users(1, 3)},
+		{"all dropped", users(1, 2), []uint{1, 2, 2}, users()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withoutUsers(tt.users, tt.ids)
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("withoutUsers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -82,6 +82,18 @@ func OptionalAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// QueryTokenMiddleware accepts the JWT as ?access_token= for clients that
+// cannot set headers, such as the browser EventSource. Chain it before the
+// auth middleware.
+func QueryTokenMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
 // GetUserFromContext extracts user claims from request context
 func GetUserFromContext(r *http.Request) (*UserClaims, bool) {
 	claims, ok := r.Context().Value(UserContextKey).(*UserClaims)
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitMiddleware applies the token-bucket policy configured for route.
+// Authenticated users are limited per account, with the stricter limit while
+// their account is new; guests are limited per IP address. Responses carry
+// RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy
+// headers. On routes that need a login, wrap it inside AuthMiddleware so
+// the user is known; on public routes such as login it limits guests.
+func RateLimitMiddleware(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		policy, ok := config.RateLimits[route]
+		if !ok || storage.RateLimits == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		now := time.Now()
+		limit := policy.Default
+		var key string
+		if claims, ok := GetUserFromContext(r); ok {
+			key = fmt.Sprintf("%s:user:%d", route, claims.UserID)
+			if isNewAccount(claims.UserID, now) {
+				limit = policy.NewAccount
+			}
+		} else {
+			key = fmt.Sprintf("%s:ip:%s", route, clientIP(r))
+		}
+
+		decision, err := storage.RateLimits.Take(key, limit.Requests, limit.Window, now)
+		if err != nil {
+			// Fail open, a broken limiter should not take the site down
+			log.Printf("Warning: rate limit check for %s failed: %v", key, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.Requests))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+		w.Header().Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Window)))
+		if !decision.Allowed {
+			log.Printf("🐢 Rate limited %s", key)
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+			http.Error(w, "Too many requests, please slow down", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+}
+
+// isNewAccount reports whether the user registered less than
+// config.NewAccountDays ago
+func isNewAccount(userID uint, now time.Time) bool {
+	var user models.User
+	if err := storage.DB.Select("created_at").First(&user, userID).Error; err != nil {
+		return true
+	}
+	return now.Sub(user.CreatedAt) < time.Duration(config.NewAccountDays)*24*time.Hour
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const RequestIDContextKey contextKey = "request_id"
+
+// RequestIDMiddleware tags every request with an ID, reusing X-Request-ID from
+// a trusted proxy when present, and echoes it in the response headers
+func RequestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 64 {
+			buf := make([]byte, 8)
+			rand.Read(buf)
+			requestID = hex.EncodeToString(buf)
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := context.WithValue(r.Context(), RequestIDContextKey, requestID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+// GetRequestID returns the ID assigned by RequestIDMiddleware
+func GetRequestID(r *http.Request) string {
+	requestID, _ := r.Context().Value(RequestIDContextKey).(string)
+	return requestID
+}
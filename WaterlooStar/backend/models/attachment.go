@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Attachment is an uploaded file. It is unlinked until used in a post or
+// comment; unlinked uploads are garbage-collected after a while. Several
+// attachments may share one blob when the same file is uploaded twice.
+type Attachment struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+	UploaderID   uint           `gorm:"not null;index" json:"uploader_id"`
+	PostID       *uint          `gorm:"index" json:"post_id,omitempty"`
+	CommentID    *uint          `gorm:"index" json:"comment_id,omitempty"`
+	FileName     string         `json:"file_name"`
+	ContentType  string         `json:"content_type"`
+	Size         int64          `json:"size"`
+	SHA256       string         `gorm:"index;not null" json:"sha256"`
+	BlobKey      string         `gorm:"index;not null" json:"-"`
+	ThumbnailKey string         `json:"-"`
+	HasThumbnail bool           `gorm:"-" json:"has_thumbnail"`
+}
+
+// AfterFind exposes whether a thumbnail exists without leaking the blob key
+func (a *Attachment) AfterFind(tx *gorm.DB) error {
+	a.HasThumbnail = a.ThumbnailKey != ""
+	return nil
+}
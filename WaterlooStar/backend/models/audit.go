@@ -0,0 +1,66 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Audit actions
+const (
+	AuditPostPin        = "post.pin"
+	AuditPostLock       = "post.lock"
+	AuditPostFeature    = "post.feature"
+	AuditContentRemove  = "content.remove"
+	AuditContentRestore = "content.restore"
+	AuditReportResolve  = "report.resolve"
+	AuditUserBan        = "user.ban"
+	AuditUserWarn       = "user.warn"
+	AuditBanLift        = "ban.lift"
+	AuditUserShadowban  = "user.shadowban"
+	AuditUserRole       = "user.role"
+	AuditUserRestore    = "user.restore"
+	AuditSectionUpdate  = "section.update"
+	AuditFilterCreate   = "filter.create"
+	AuditFilterDelete   = "filter.delete"
+	AuditWebhookCreate  = "webhook.create"
+	AuditWebhookUpdate  = "webhook.update"
+	AuditWebhookDelete  = "webhook.delete"
+)
+
+// JSONSnapshot is a JSON document stored as jsonb and returned unquoted
+type JSONSnapshot string
+
+func (s JSONSnapshot) MarshalJSON() ([]byte, error) {
+	if s == "" {
+		return []byte("null"), nil
+	}
+	return []byte(s), nil
+}
+
+// AuditEntry records one privileged action. Entries are append-only: the
+// hooks below refuse updates and deletes.
+type AuditEntry struct {
+	ID            uint         `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time    `gorm:"index" json:"created_at"`
+	ActorID       uint         `gorm:"not null;index" json:"actor_id"`
+	ActorUsername string       `json:"actor_username"`
+	Action        string       `gorm:"not null;index" json:"action"`
+	TargetType    string       `gorm:"not null;index:idx_audit_entries_target" json:"target_type"`
+	TargetID      uint         `gorm:"index:idx_audit_entries_target" json:"target_id"`
+	Before        JSONSnapshot `gorm:"type:jsonb" json:"before"`
+	After         JSONSnapshot `gorm:"type:jsonb" json:"after"`
+	RequestID     string       `gorm:"index" json:"request_id"`
+	Note          string       `json:"note,omitempty"`
+}
+
+var ErrAuditAppendOnly = errors.New("audit entries are append-only")
+
+func (a *AuditEntry) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditAppendOnly
+}
+
+func (a *AuditEntry) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditAppendOnly
+}
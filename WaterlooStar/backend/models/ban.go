@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Ban stops a user from posting, commenting and liking; banned users keep
+// read access. An empty Section means a site-wide ban and a nil ExpiresAt
+// means it never expires. Expired or lifted bans are kept for the record.
+type Ban struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	UserID      uint       `gorm:"not null;index" json:"user_id"`
+	Section     string     `gorm:"index" json:"section,omitempty"`
+	Reason      string     `json:"reason"`
+	ModeratorID uint       `gorm:"not null" json:"moderator_id"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	LiftedAt    *time.Time `json:"lifted_at,omitempty"` // Set when a moderator lifts the ban early
+	LiftedByID  *uint      `json:"lifted_by_id,omitempty"`
+}
+
+// IsActiveAt reports whether the ban is in force
+func (b *Ban) IsActiveAt(now time.Time) bool {
+	return b.LiftedAt == nil && (b.ExpiresAt == nil || now.Before(*b.ExpiresAt))
+}
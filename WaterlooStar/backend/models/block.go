@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// UserBlock is a two-way block: neither user sees the other's content, and
+// the blocked user cannot reply to, mention or message the blocker.
+type UserBlock struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_blocks_user_blocked" json:"user_id"`
+	BlockedID uint      `gorm:"not null;uniqueIndex:idx_user_blocks_user_blocked;index" json:"blocked_id"`
+}
+
+// UserMute is one-way: the muted user's content is hidden from UserID only.
+// The muted user is not told and is not otherwise restricted.
+type UserMute struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_user_mutes_user_muted" json:"user_id"`
+	MutedID   uint      `gorm:"not null;uniqueIndex:idx_user_mutes_user_muted" json:"muted_id"`
+}
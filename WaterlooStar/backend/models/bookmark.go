@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// Bookmark is a post saved by a user to read later. Bookmarks are hard-deleted
+// so that the unique (user_id, post_id) index allows saving a post again.
+type Bookmark struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_bookmarks_user_post" json:"user_id"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_bookmarks_user_post" json:"post_id"`
+	Folder    string    `gorm:"index" json:"folder,omitempty"` // Optional named folder, empty for unsorted
+	Post      Post      `json:"post,omitempty" gorm:"foreignKey:PostID"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Digest frequencies
+const (
+	DigestNone   = "none"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// EmailPreference holds what a user wants by email. Users without a row get
+// no email besides account mail such as verification.
+type EmailPreference struct {
+	ID           uint       `gorm:"primaryKey" json:"-"`
+	CreatedAt    time.Time  `json:"-"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	UserID       uint       `gorm:"not null;uniqueIndex" json:"-"`
+	Replies      bool       `gorm:"not null;default:false" json:"replies"` // Email each new reply notification
+	Digest       string     `gorm:"not null;default:none;index" json:"digest"`
+	LastDigestAt *time.Time `json:"last_digest_at"` // End of the period the last digest covered
+}
+
+// ValidDigest reports whether frequency is a supported digest frequency
+func ValidDigest(frequency string) bool {
+	return frequency == DigestNone || frequency == DigestDaily || frequency == DigestWeekly
+}
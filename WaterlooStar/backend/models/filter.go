@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Content filter actions, from least to most severe
+const (
+	FilterActionMask  = "mask"  // Replace the match with asterisks and publish
+	FilterActionFlag  = "flag"  // Publish hidden and queue for moderator review
+	FilterActionBlock = "block" // Reject the submission
+)
+
+// ContentFilter is a banned word or regular expression checked against new
+// posts and comments. Filters with an empty Section apply everywhere.
+type ContentFilter struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Section     string    `gorm:"index" json:"section"`
+	Pattern     string    `gorm:"not null" json:"pattern"`
+	IsRegex     bool      `gorm:"default:false" json:"is_regex"` // Otherwise Pattern is a whole word, matched case-insensitively
+	Action      string    `gorm:"not null" json:"action"`
+	CreatedByID uint      `json:"created_by_id"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Follow kinds
+const (
+	FollowUser    = "user"
+	FollowSection = "section"
+	FollowTag     = "tag"
+)
+
+// Follow adds a user, section or tag to UserID's home feed. FollowedID is set
+// for users; Name holds the section slug or the lowercased tag.
+type Follow struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	UserID     uint      `gorm:"not null;uniqueIndex:idx_follows_user_target" json:"-"`
+	Kind       string    `gorm:"not null;uniqueIndex:idx_follows_user_target;index:idx_follows_target" json:"kind"`
+	FollowedID uint      `gorm:"not null;default:0;uniqueIndex:idx_follows_user_target;index:idx_follows_target" json:"followed_id,omitempty"`
+	Name       string    `gorm:"not null;default:'';uniqueIndex:idx_follows_user_target;index:idx_follows_target" json:"name,omitempty"`
+}
+
+// FeedCache records when a user's materialized feed was last built, see
+// package feed. At is the reference time the cached scores were computed for.
+type FeedCache struct {
+	UserID      uint      `gorm:"primaryKey;autoIncrement:false"`
+	Sort        string    `gorm:"primaryKey"`
+	At          time.Time `gorm:"not null"`
+	RefreshedAt time.Time `gorm:"not null"`
+}
+
+// FeedEntry is one post in a materialized feed
+type FeedEntry struct {
+	UserID      uint      `gorm:"primaryKey;autoIncrement:false"`
+	Sort        string    `gorm:"primaryKey"`
+	PostID      uint      `gorm:"primaryKey;autoIncrement:false"`
+	PublishedAt time.Time `gorm:"not null"`
+	Score       float64   `gorm:"not null"`
+}
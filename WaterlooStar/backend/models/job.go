@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Job is a unit of background work in the durable queue, see package queue.
+// A job is pending while DoneAt and FailedAt are both nil.
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Queue       string     `gorm:"not null;index:idx_jobs_pending" json:"queue"`
+	Payload     string     `gorm:"type:text;not null" json:"payload"` // JSON, decoded by the queue's handler
+	Attempts    int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts int        `gorm:"not null;default:5" json:"max_attempts"`
+	RunAt       time.Time  `gorm:"not null;index:idx_jobs_pending" json:"run_at"` // Next attempt, or lease expiry while running
+	DoneAt      *time.Time `gorm:"index" json:"done_at,omitempty"`
+	FailedAt    *time.Time `json:"failed_at,omitempty"` // Set after the last attempt failed
+	LastError   string     `json:"last_error,omitempty"`
+}
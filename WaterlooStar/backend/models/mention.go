@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Mention is one @username in the content of a post or comment that resolved
+// to a user. Start and End are offsets into Content in Unicode code points,
+// End exclusive, so the frontend can turn the span into a link.
+type Mention struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	CreatedAt time.Time `json:"-"`
+	PostID    uint      `gorm:"not null;index" json:"-"`
+	CommentID *uint     `gorm:"index" json:"-"`                // Nil for mentions in the post itself
+	AuthorID  uint      `gorm:"not null" json:"-"`             // Who wrote the mention
+	UserID    uint      `gorm:"not null;index" json:"user_id"` // Who was mentioned
+	Username  string    `gorm:"not null" json:"username"`
+	Start     int       `gorm:"column:span_start;not null" json:"start"`
+	End       int       `gorm:"column:span_end;not null" json:"end"` // END is reserved in SQL
+}
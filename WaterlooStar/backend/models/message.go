@@ -0,0 +1,52 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Conversation is a private thread of messages between two or more users.
+// A one-to-one conversation has a DirectKey so that each pair of users has
+// at most one; groups are created anew every time.
+type Conversation struct {
+	ID            uint                      `gorm:"primaryKey" json:"id"`
+	CreatedAt     time.Time                 `json:"created_at"`
+	UpdatedAt     time.Time                 `json:"updated_at"`
+	CreatorID     uint                      `gorm:"not null" json:"creator_id"`
+	IsGroup       bool                      `gorm:"not null;default:false" json:"is_group"`
+	Title         string                    `json:"title,omitempty"` // Optional, groups only
+	DirectKey     *string                   `gorm:"uniqueIndex" json:"-"`
+	LastMessageAt *time.Time                `gorm:"index" json:"last_message_at,omitempty"`
+	Participants  []ConversationParticipant `json:"participants,omitempty" gorm:"foreignKey:ConversationID"`
+}
+
+// DirectKey identifies the one-to-one conversation between two users
+func DirectKey(a, b uint) string {
+	if a > b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("%d:%d", a, b)
+}
+
+// ConversationParticipant is a member of a conversation. LastReadMessageID is
+// the read receipt: the newest message the member has seen.
+type ConversationParticipant struct {
+	ID                uint       `gorm:"primaryKey" json:"-"`
+	CreatedAt         time.Time  `json:"joined_at"`
+	ConversationID    uint       `gorm:"not null;uniqueIndex:idx_conversation_participants_member" json:"-"`
+	UserID            uint       `gorm:"not null;uniqueIndex:idx_conversation_participants_member;index" json:"user_id"`
+	Username          string     `gorm:"-" json:"username"` // Filled in on load
+	LastReadMessageID uint       `gorm:"not null;default:0" json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at,omitempty"`
+	IsMuted           bool       `gorm:"not null;default:false" json:"is_muted"` // No alerts for new messages
+}
+
+// Message is one direct message. Messages cannot be edited.
+type Message struct {
+	ID             uint      `gorm:"primaryKey;index:idx_messages_conversation_id,priority:2" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uint      `gorm:"not null;index:idx_messages_conversation_id,priority:1" json:"conversation_id"`
+	SenderID       uint      `gorm:"not null" json:"sender_id"`
+	Sender         string    `gorm:"not null" json:"sender"` // Username when sent
+	Content        string    `gorm:"type:text;not null" json:"content"`
+}
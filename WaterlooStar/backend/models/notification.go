@@ -0,0 +1,90 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Notification types
+const (
+	NotifyReply      = "reply"      // Someone commented on your post
+	NotifyThread     = "thread"     // Someone commented on a post you subscribed to
+	NotifyLike       = "like"       // People liked your post, aggregated per post
+	NotifyMention    = "mention"    // Someone mentioned you
+	NotifyModeration = "moderation" // A moderator acted on your content or account
+)
+
+// NotificationTypes lists the types users can switch off. Moderation
+// notifications are always delivered.
+var NotificationTypes = []string{NotifyReply, NotifyThread, NotifyLike, NotifyMention}
+
+// AutoSubscribe is stored as a NotificationPreference next to the types:
+// when enabled, commenting on a post subscribes to it. It is off by default.
+const AutoSubscribe = "auto_subscribe"
+
+// Notification tells UserID that something happened. Unread notifications
+// with the same GroupKey are aggregated into one: ActorCount counts the
+// actors and ActorName is the most recent one.
+type Notification struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"` // When the latest event was added
+	UserID      uint       `gorm:"not null;index:idx_notifications_user_read" json:"-"`
+	Type        string     `gorm:"not null" json:"type"`
+	GroupKey    string     `gorm:"index" json:"-"`
+	ActorID     uint       `json:"-"`                               // Hidden so that anonymous actors stay anonymous
+	ActorName   string     `json:"actor_name"`                      // Username, pseudonym or "A moderator"
+	IsAnonymous bool       `gorm:"not null;default:false" json:"-"` // The actor posted anonymously, so blocks and mutes do not hide it
+	ActorCount  int        `gorm:"not null;default:1" json:"actor_count"`
+	PostID      *uint      `json:"post_id,omitempty"`
+	CommentID   *uint      `json:"comment_id,omitempty"`
+	Subject     string     `json:"subject,omitempty"` // Title of the post concerned
+	Body        string     `json:"body,omitempty"`    // Comment excerpt or moderator note
+	ReadAt      *time.Time `gorm:"index:idx_notifications_user_read" json:"read_at"`
+	Message     string     `gorm:"-" json:"message"` // Human-readable summary, built on load
+}
+
+// NotificationPreference switches one notification type off or on for a
+// user. Types without a row are on.
+type NotificationPreference struct {
+	ID      uint   `gorm:"primaryKey" json:"-"`
+	UserID  uint   `gorm:"not null;uniqueIndex:idx_notification_preferences_user_type" json:"-"`
+	Type    string `gorm:"not null;uniqueIndex:idx_notification_preferences_user_type" json:"type"`
+	Enabled bool   `gorm:"not null" json:"enabled"`
+}
+
+// AfterFind builds Message
+func (n *Notification) AfterFind(tx *gorm.DB) error {
+	n.Message = n.Summary()
+	return nil
+}
+
+// Summary describes the notification, e.g. "alice and 4 others liked your post"
+func (n *Notification) Summary() string {
+	actors := n.ActorName
+	switch {
+	case n.ActorCount == 2:
+		actors += " and 1 other"
+	case n.ActorCount > 2:
+		actors += fmt.Sprintf(" and %d others", n.ActorCount-1)
+	}
+
+	switch n.Type {
+	case NotifyReply:
+		if n.ActorCount > 1 {
+			return fmt.Sprintf("%s commented on your post %q", actors, n.Subject)
+		}
+		return fmt.Sprintf("%s replied to your post %q", actors, n.Subject)
+	case NotifyThread:
+		return fmt.Sprintf("%s commented on %q", actors, n.Subject)
+	case NotifyLike:
+		return fmt.Sprintf("%s liked your post %q", actors, n.Subject)
+	case NotifyMention:
+		return fmt.Sprintf("%s mentioned you in %q", actors, n.Subject)
+	case NotifyModeration:
+		return n.Body
+	}
+	return ""
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// Poll is attached to a post and created together with it
+type Poll struct {
+	ID                   uint         `gorm:"primaryKey" json:"id"`
+	CreatedAt            time.Time    `json:"created_at"`
+	UpdatedAt            time.Time    `json:"updated_at"`
+	PostID               uint         `gorm:"not null;uniqueIndex" json:"post_id"`
+	Question             string       `gorm:"not null" json:"question"`
+	MultipleChoice       bool         `gorm:"default:false" json:"multiple_choice"`
+	EndsAt               *time.Time   `json:"ends_at,omitempty"`
+	Anonymous            *bool        `gorm:"not null;default:true" json:"anonymous"`       // Hide who voted for what. A pointer so that an explicit false is saved.
+	HideResultsUntilVote bool         `gorm:"default:false" json:"hide_results_until_vote"` // Results stay hidden until you vote or the poll ends
+	Options              []PollOption `json:"options" gorm:"foreignKey:PollID"`
+
+	// Computed per viewer, see handlers.annotatePolls
+	TotalVotes    uint   `json:"total_votes" gorm:"-"`
+	MyVotes       []uint `json:"my_votes" gorm:"-"` // Option IDs the viewer voted for
+	IsClosed      bool   `json:"is_closed" gorm:"-"`
+	ResultsHidden bool   `json:"results_hidden" gorm:"-"`
+}
+
+type PollOption struct {
+	ID       uint   `gorm:"primaryKey" json:"id"`
+	PollID   uint   `gorm:"not null;index" json:"poll_id"`
+	Text     string `gorm:"not null" json:"text"`
+	Position int    `gorm:"not null;default:0" json:"position"`
+
+	// Computed per viewer, see handlers.annotatePolls
+	Votes  uint     `json:"votes" gorm:"-"`
+	Voters []string `json:"voters,omitempty" gorm:"-"` // Usernames, public polls only
+}
+
+// PollVote is one user's vote for one option. Multiple-choice polls have one
+// row per chosen option.
+type PollVote struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	PollID    uint      `gorm:"not null;index;uniqueIndex:idx_poll_votes_poll_option_user" json:"poll_id"`
+	OptionID  uint      `gorm:"not null;uniqueIndex:idx_poll_votes_poll_option_user" json:"option_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_poll_votes_poll_option_user" json:"user_id"`
+}
+
+// IsAnonymous reports whether votes are secret, the default
+func (p *Poll) IsAnonymous() bool {
+	return p.Anonymous == nil || *p.Anonymous
+}
+
+// IsClosedAt reports whether voting has ended
+func (p *Poll) IsClosedAt(now time.Time) bool {
+	return p.EndsAt != nil && !now.Before(*p.EndsAt)
+}
@@ -1,39 +1,177 @@
 package models
 
 import (
+	"WaterlooStar/backend/render"
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Pin scopes: global pins show at the top of every listing, section pins
+// only at the top of their own section.
+const (
+	PinScopeGlobal  = "global"
+	PinScopeSection = "section"
+)
+
+// Post statuses. Only published posts are visible in listings; scheduled
+// posts are published by the background scheduler once PublishAt passes.
+const (
+	PostStatusDraft     = "draft"
+	PostStatusScheduled = "scheduled"
+	PostStatusPublished = "published"
+)
+
 type Post struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	Section   string         `json:"section"`
-	Title     string         `json:"title"`
-	Content   string         `json:"content"`
-	Author    string         `json:"author"` // Username for display
-	AuthorID  uint           `gorm:"not null" json:"author_id"`
-	Tags      string         `json:"tags,omitempty"`
-	Views     uint           `json:"views" gorm:"default:0"`
-	Likes     uint           `json:"likes" gorm:"default:0"`
-	IsLiked   bool           `json:"is_liked" gorm:"-"` // Computed field for current user
-	Comments  []Comment      `json:"comments,omitempty" gorm:"foreignKey:PostID"`
-	PostLikes []PostLike     `json:"post_likes,omitempty" gorm:"foreignKey:PostID"`
-	User      User           `json:"user,omitempty" gorm:"foreignKey:AuthorID"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletionAuditID *uint          `json:"-"` // Audit entry of the moderator action that deleted the post
+	Section         string         `json:"section"`
+	Title           string         `json:"title"`
+	Content         string         `json:"content"`
+	ContentFormat   string         `json:"content_format" gorm:"not null;default:plain"` // render.FormatPlain or render.FormatMarkdown
+	ContentHTML     string         `json:"content_html"`                                 // Sanitized HTML, rendered on save
+	ContentHash     string         `gorm:"index" json:"-"`                               // policy.Fingerprint of the content, for duplicate detection
+	Author          string         `json:"author"`                                       // Username for display, or pseudonym when anonymous
+	AuthorID        uint           `gorm:"not null" json:"author_id"`
+	IsAnonymous     bool           `json:"is_anonymous" gorm:"default:false"`
+	Tags            string         `json:"tags,omitempty"`
+	Views           uint           `json:"views" gorm:"default:0"`
+	Likes           uint           `json:"likes" gorm:"default:0"`
+	Status          string         `json:"status" gorm:"not null;default:published;index"`
+	PublishAt       *time.Time     `json:"publish_at,omitempty"`   // When a scheduled post goes live
+	PublishedAt     *time.Time     `json:"published_at,omitempty"` // When the post actually went live
+	IsPinned        bool           `json:"is_pinned" gorm:"default:false;index"`
+	PinScope        string         `json:"pin_scope,omitempty"` // PinScopeGlobal or PinScopeSection
+	PinOrder        int            `json:"pin_order" gorm:"default:0"`
+	IsLocked        bool           `json:"is_locked" gorm:"default:false"` // No new comments or likes
+	IsFeatured      bool           `json:"is_featured" gorm:"default:false"`
+	IsHidden        bool           `json:"is_hidden" gorm:"default:false;index"` // Hidden by reports pending review
+	IsLiked         bool           `json:"is_liked" gorm:"-"`                    // Computed field for current user
+	IsBookmarked    bool           `json:"is_bookmarked" gorm:"-"`               // Computed field for current user
+	IsSubscribed    bool           `json:"is_subscribed" gorm:"-"`               // Computed field for current user
+	IsOwn           bool           `json:"is_own" gorm:"-"`                      // Computed field for current user
+	RevealAuthor    bool           `json:"-" gorm:"-"`                           // Set for moderators to see anonymous authors
+	Comments        []Comment      `json:"comments,omitempty" gorm:"foreignKey:PostID"`
+	Attachments     []Attachment   `json:"attachments,omitempty" gorm:"foreignKey:PostID"`
+	Poll            *Poll          `json:"poll,omitempty" gorm:"foreignKey:PostID"`
+	Mentions        []Mention      `json:"mentions,omitempty" gorm:"foreignKey:PostID"`
+	AttachmentIDs   []uint         `json:"attachment_ids,omitempty" gorm:"-"` // Uploads to link on create
+	PostLikes       []PostLike     `json:"post_likes,omitempty" gorm:"foreignKey:PostID"`
+	User            User           `json:"user,omitempty" gorm:"foreignKey:AuthorID"`
 }
 
 type Comment struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
-	PostID    uint           `gorm:"not null" json:"post_id"`
-	Content   string         `json:"content"`
-	Author    string         `json:"author"` // Username for display
-	AuthorID  uint           `gorm:"not null" json:"author_id"`
-	Likes     uint           `json:"likes" gorm:"default:0"`
-	User      User           `json:"user,omitempty" gorm:"foreignKey:AuthorID"`
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletionAuditID *uint          `json:"-"` // Audit entry of the moderator action that deleted the comment
+	PostID          uint           `gorm:"not null" json:"post_id"`
+	Content         string         `json:"content"`
+	ContentFormat   string         `json:"content_format" gorm:"not null;default:plain"`
+	ContentHTML     string         `json:"content_html"`
+	ContentHash     string         `gorm:"index" json:"-"` // policy.Fingerprint of the content, for duplicate detection
+	Author          string         `json:"author"`         // Username for display, or pseudonym when anonymous
+	AuthorID        uint           `gorm:"not null" json:"author_id"`
+	IsAnonymous     bool           `json:"is_anonymous" gorm:"default:false"`
+	IsHidden        bool           `json:"is_hidden" gorm:"default:false;index"` // Hidden by reports pending review
+	Likes           uint           `json:"likes" gorm:"default:0"`
+	User            User           `json:"user,omitempty" gorm:"foreignKey:AuthorID"`
+	Attachments     []Attachment   `json:"attachments,omitempty" gorm:"foreignKey:CommentID"`
+	Mentions        []Mention      `json:"mentions,omitempty" gorm:"foreignKey:CommentID"`
+	AttachmentIDs   []uint         `json:"attachment_ids,omitempty" gorm:"-"` // Uploads to link on create
+	IsOwn           bool           `json:"is_own" gorm:"-"`                   // Computed field for current user
+	RevealAuthor    bool           `json:"-" gorm:"-"`                        // Set for moderators to see anonymous authors
+}
+
+// IsPublished reports whether the post has gone live
+func (p *Post) IsPublished() bool {
+	return p.Status == "" || p.Status == PostStatusPublished
+}
+
+// IsVisible reports whether the post is publicly visible: published and not
+// hidden pending review
+func (p *Post) IsVisible() bool {
+	return p.IsPublished() && !p.IsHidden
+}
+
+// BeforeSave caches the rendered HTML so reads never render Markdown
+func (p *Post) BeforeSave(tx *gorm.DB) error {
+	if p.ContentFormat == "" {
+		p.ContentFormat = render.FormatPlain
+	}
+	p.ContentHTML = render.HTML(p.ContentFormat, p.Content)
+	return nil
+}
+
+// BeforeSave caches the rendered HTML so reads never render Markdown
+func (c *Comment) BeforeSave(tx *gorm.DB) error {
+	if c.ContentFormat == "" {
+		c.ContentFormat = render.FormatPlain
+	}
+	c.ContentHTML = render.HTML(c.ContentFormat, c.Content)
+	return nil
+}
+
+// AfterDelete deletes the post's comments, likes and attachments along with
+// it. Restoring the post brings back whatever was deleted at or after its own
+// deletion time, see handlers.RestoreTrash.
+func (p *Post) AfterDelete(tx *gorm.DB) error {
+	db := tx.Session(&gorm.Session{NewDB: true})
+	commentIDs := db.Model(&Comment{}).Select("id").Where("post_id = ?", p.ID)
+	if err := db.Where("post_id = ? OR comment_id IN (?)", p.ID, commentIDs).Delete(&Attachment{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("post_id = ?", p.ID).Delete(&PostLike{}).Error; err != nil {
+		return err
+	}
+	return db.Where("post_id = ?", p.ID).Delete(&Comment{}).Error
+}
+
+// AfterDelete deletes the comment's attachments along with it
+func (c *Comment) AfterDelete(tx *gorm.DB) error {
+	return tx.Session(&gorm.Session{NewDB: true}).Where("comment_id = ?", c.ID).Delete(&Attachment{}).Error
+}
+
+// MarshalJSON hides the real author of anonymous posts, including as the
+// uploader of their attachments. This applies wherever a post is serialized
+// (listings, bookmarks, User.Posts, live events), so only code that sets
+// RevealAuthor can expose it through a post.
+func (p Post) MarshalJSON() ([]byte, error) {
+	type plainPost Post
+	if p.IsAnonymous && !p.RevealAuthor {
+		p.AuthorID = 0
+		p.User = User{}
+		p.Attachments = withoutUploaders(p.Attachments)
+	}
+	return json.Marshal(plainPost(p))
+}
+
+// MarshalJSON hides the real author of anonymous comments, see Post.MarshalJSON
+func (c Comment) MarshalJSON() ([]byte, error) {
+	type plainComment Comment
+	if c.IsAnonymous && !c.RevealAuthor {
+		c.AuthorID = 0
+		c.User = User{}
+		c.Attachments = withoutUploaders(c.Attachments)
+	}
+	return json.Marshal(plainComment(c))
+}
+
+// withoutUploaders copies attachments with the uploader cleared. Uploads can
+// only be linked by their uploader, so it is always the author.
+func withoutUploaders(attachments []Attachment) []Attachment {
+	if attachments == nil {
+		return nil
+	}
+	hidden := make([]Attachment, len(attachments))
+	for i, attachment := range attachments {
+		attachment.UploaderID = 0
+		hidden[i] = attachment
+	}
+	return hidden
 }
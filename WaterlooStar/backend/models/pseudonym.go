@@ -0,0 +1,22 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ThreadPseudonym gives an anonymous participant a stable name within one
+// thread, e.g. "Anonymous Goose #3". Numbers are assigned in order of first
+// anonymous contribution.
+type ThreadPseudonym struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_thread_pseudonyms_post_user;uniqueIndex:idx_thread_pseudonyms_post_number" json:"post_id"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_thread_pseudonyms_post_user" json:"-"`
+	Number    int       `gorm:"not null;uniqueIndex:idx_thread_pseudonyms_post_number" json:"number"`
+}
+
+// DisplayName is shown in place of the username
+func (p *ThreadPseudonym) DisplayName() string {
+	return fmt.Sprintf("Anonymous Goose #%d", p.Number)
+}
@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// RateLimitBucket is a token bucket shared by all instances when rate limits
+// are kept in Postgres, see storage.PostgresRateLimitStore
+type RateLimitBucket struct {
+	Key       string    `gorm:"primaryKey" json:"key"`
+	Tokens    float64   `gorm:"not null" json:"tokens"`
+	UpdatedAt time.Time `gorm:"index" json:"updated_at"`
+}
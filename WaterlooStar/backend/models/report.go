@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+)
+
+// Report target types
+const (
+	ReportTargetPost    = "post"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+)
+
+// Report statuses
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusDismissed = "dismissed"
+	ReportStatusActioned  = "actioned"
+)
+
+// ReportReasonAutomated marks reports filed by the content policy. Their
+// ReporterID is 0 and Details lists the rules that matched.
+const ReportReasonAutomated = "automated"
+
+// ReportReasons lists the accepted reason categories
+var ReportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"sexual":         true,
+	"violence":       true,
+	"misinformation": true,
+	"academic":       true, // Academic integrity, e.g. sharing exam answers
+	"other":          true,
+}
+
+// Report flags a post, comment or user for moderator review. Each user can
+// report the same target only once. Reports with ReporterID 0 come from the
+// content policy rather than a user.
+type Report struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ReporterID   uint       `gorm:"not null;uniqueIndex:idx_reports_reporter_target" json:"reporter_id"`
+	TargetType   string     `gorm:"not null;uniqueIndex:idx_reports_reporter_target;index:idx_reports_target" json:"target_type"`
+	TargetID     uint       `gorm:"not null;uniqueIndex:idx_reports_reporter_target;index:idx_reports_target" json:"target_id"`
+	TargetUserID uint       `gorm:"index" json:"target_user_id"` // Author of the reported content, or the reported user
+	Section      string     `gorm:"index" json:"section,omitempty"`
+	Reason       string     `gorm:"not null" json:"reason"`
+	Details      string     `json:"details,omitempty"`
+	Status       string     `gorm:"not null;default:open;index" json:"status"`
+	ResolvedByID *uint      `json:"resolved_by_id,omitempty"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+	Resolution   string     `json:"resolution,omitempty"` // The moderation action taken
+}
+
+// Moderation actions
+const (
+	ModActionDismiss       = "dismiss"
+	ModActionRemoveContent = "remove_content"
+	ModActionWarn          = "warn"
+	ModActionBan           = "ban"
+)
+
+// ModerationAction records every decision a moderator takes on reports
+type ModerationAction struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	ModeratorID  uint      `gorm:"not null;index" json:"moderator_id"`
+	ReportID     *uint     `gorm:"index" json:"report_id,omitempty"`
+	Action       string    `gorm:"not null" json:"action"`
+	TargetType   string    `gorm:"not null" json:"target_type"`
+	TargetID     uint      `gorm:"not null" json:"target_id"`
+	TargetUserID uint      `gorm:"index" json:"target_user_id"`
+	Note         string    `json:"note,omitempty"`
+}
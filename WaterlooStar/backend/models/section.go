@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// Section holds per-section settings. Posts refer to sections by Slug.
+type Section struct {
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Slug           string    `gorm:"uniqueIndex;not null" json:"slug"`
+	Name           string    `gorm:"not null" json:"name"`
+	Description    string    `json:"description"`
+	AllowAnonymous bool      `gorm:"default:false" json:"allow_anonymous"` // Members may post and comment anonymously
+}
+
+// DefaultSections are created on first start, matching the frontend's section list
+var DefaultSections = []Section{
+	{Slug: "housing", Name: "Student Housing", Description: "Find rooms, apartments, roommates, and housing tips"},
+	{Slug: "deals", Name: "Deals & Discounts", Description: "Share supermarket discounts and money-saving tips"},
+	{Slug: "news", Name: "Campus News", Description: "Latest local and campus news, events, and announcements"},
+	{Slug: "events", Name: "Events & Activities", Description: "Upcoming student events, workshops, and meetups"},
+	{Slug: "help", Name: "Q&A / Help Desk", Description: "Ask questions and get advice from fellow students", AllowAnonymous: true},
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ThreadSubscription makes UserID hear about new comments on PostID. Authors
+// are subscribed to their own posts when they create them, commenters when
+// they turned AutoSubscribe on. Unsubscribing deletes the row.
+type ThreadSubscription struct {
+	ID        uint      `gorm:"primaryKey" json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_thread_subscriptions_user_post" json:"-"`
+	PostID    uint      `gorm:"not null;uniqueIndex:idx_thread_subscriptions_user_post;index" json:"post_id"`
+	Post      Post      `json:"post,omitempty" gorm:"foreignKey:PostID"`
+}
@@ -7,11 +7,19 @@ import (
 	"gorm.io/gorm"
 )
 
+// User roles. Moderators and admins can pin, lock and feature posts.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
 type User struct {
 	ID                 uint           `gorm:"primaryKey" json:"id"`
 	CreatedAt          time.Time      `json:"created_at"`
 	UpdatedAt          time.Time      `json:"updated_at"`
 	DeletedAt          gorm.DeletedAt `gorm:"index" json:"-"`
+	DeletionAuditID    *uint          `json:"-"` // Audit entry of the admin action that deleted the user
 	Username           string         `gorm:"uniqueIndex;not null" json:"username"`
 	Email              string         `gorm:"uniqueIndex;not null" json:"email"`
 	PasswordHash       string         `gorm:"not null" json:"-"`
@@ -23,6 +31,8 @@ type User struct {
 	Major              string         `json:"major,omitempty"`
 	ContactInfo        string         `json:"contact_info,omitempty"`
 	Bio                string         `json:"bio,omitempty"`
+	Role               string         `gorm:"not null;default:user" json:"role"`
+	IsShadowbanned     bool           `gorm:"default:false;index" json:"-"` // Content is only visible to the user and moderators
 	Posts              []Post         `json:"posts,omitempty" gorm:"foreignKey:AuthorID"`
 	Comments           []Comment      `json:"comments,omitempty" gorm:"foreignKey:AuthorID"`
 }
@@ -42,3 +52,8 @@ func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
 	return err == nil
 }
+
+// IsModerator reports whether the user has moderator privileges (admins included)
+func (u *User) IsModerator() bool {
+	return u.Role == RoleModerator || u.Role == RoleAdmin
+}
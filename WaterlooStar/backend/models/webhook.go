@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+// Webhook events
+const (
+	EventPostCreated    = "post.created"
+	EventCommentCreated = "comment.created"
+	EventReportCreated  = "report.created"
+	EventUserRegistered = "user.registered"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to
+var WebhookEvents = []string{EventPostCreated, EventCommentCreated, EventReportCreated, EventUserRegistered}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"   // Queued or waiting for a retry
+	DeliverySucceeded = "succeeded" // The endpoint answered 2xx
+	DeliveryFailed    = "failed"    // Out of attempts, or the webhook was disabled
+)
+
+// Webhook posts signed JSON payloads about forum events to an external URL.
+// Events and Sections are comma-separated; empty Sections matches every
+// section, and events that have no section, such as user.registered, are
+// always sent.
+type Webhook struct {
+	ID                  uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+	CreatedByID         uint       `json:"created_by_id"`
+	URL                 string     `gorm:"not null" json:"url"`
+	Description         string     `json:"description,omitempty"`
+	Secret              string     `gorm:"not null" json:"-"` // HMAC-SHA256 key, only shown when set
+	Events              string     `gorm:"not null" json:"events"`
+	Sections            string     `json:"sections"`
+	IsActive            bool       `gorm:"not null" json:"is_active"`                      // No DB default, so that creating an inactive webhook saves false
+	ConsecutiveFailures int        `gorm:"not null;default:0" json:"consecutive_failures"` // Failed attempts since the last success
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`                          // Set when disabled after repeated failures
+}
+
+// WebhookDelivery is the delivery log: one event sent to one webhook, with
+// the outcome of its latest attempt. Redeliveries are new rows that point to
+// the delivery they repeat.
+type WebhookDelivery struct {
+	ID             uint       `gorm:"primaryKey;index:idx_webhook_deliveries_webhook_id,priority:2" json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	WebhookID      uint       `gorm:"not null;index:idx_webhook_deliveries_webhook_id,priority:1" json:"webhook_id"`
+	EventID        string     `gorm:"not null;index" json:"event_id"` // The same for every webhook and redelivery of an event
+	Event          string     `gorm:"not null" json:"event"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"not null;index" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	Error          string     `json:"error,omitempty"`
+	DurationMS     int64      `gorm:"column:duration_ms" json:"duration_ms"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	RedeliveryOfID *uint      `json:"redelivery_of_id,omitempty"`
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/mail"
+	"WaterlooStar/backend/models"
+	"fmt"
+	"html/template"
+	"log"
+	"net/url"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var replyEmail = template.Must(template.New("reply").Parse(`<html>
+<body style="font-family: Arial, sans-serif;">
+	<p>{{.Message}}</p>
+	{{if .Body}}<blockquote style="border-left: 3px solid #d4a574; margin: 0; padding-left: 12px; color: #444;">{{.Body}}</blockquote>{{end}}
+	<p><a href="{{.PostURL}}" style="color: #d4a574;">View the discussion</a></p>
+	<p style="color: #888; font-size: 12px;">You get this email because reply emails are on.
+		<a href="{{.UnsubscribeURL}}" style="color: #888;">Unsubscribe from reply emails</a>.</p>
+</body>
+</html>
+`))
+
+// PostURL links to a post on the site
+func PostURL(section string, postID uint) string {
+	return fmt.Sprintf("%s/section/%s#post-%d", strings.TrimRight(config.SiteURL, "/"), url.PathEscape(section), postID)
+}
+
+// emailReply queues an email about a new reply notification for recipients
+// who turned reply emails on
+func emailReply(db *gorm.DB, notification *models.Notification) {
+	var count int64
+	db.Model(&models.EmailPreference{}).Where("user_id = ? AND replies", notification.UserID).Count(&count)
+	if count == 0 || notification.PostID == nil {
+		return
+	}
+	var user models.User
+	if err := db.Select("id", "email", "is_email_verified").First(&user, notification.UserID).Error; err != nil || !user.IsEmailVerified {
+		return
+	}
+	var post models.Post
+	if err := db.Select("id", "section").First(&post, *notification.PostID).Error; err != nil {
+		return
+	}
+
+	var html strings.Builder
+	err := replyEmail.Execute(&html, map[string]string{
+		"Message":        notification.Summary(),
+		"Body":           notification.Body,
+		"PostURL":        PostURL(post.Section, post.ID),
+		"UnsubscribeURL": mail.UnsubscribeURL(user.ID, mail.UnsubscribeReplies),
+	})
+	if err == nil {
+		err = mail.Enqueue(db, mail.Message{
+			To:      user.Email,
+			Subject: notification.Summary(),
+			HTML:    html.String(),
+			Headers: mail.UnsubscribeHeaders(user.ID, mail.UnsubscribeReplies),
+		})
+	}
+	if err != nil {
+		log.Printf("Warning: failed to queue reply email for user %d: %v", user.ID, err)
+	}
+}
@@ -0,0 +1,200 @@
+// Package notify is the notification service. Handlers describe what happened
+// as an Event and Send decides whether and how the recipient hears about it.
+package notify
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/realtime"
+	"WaterlooStar/backend/visibility"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Event is something a user should hear about
+type Event struct {
+	UserID    uint // Recipient
+	Type      string
+	ActorID   uint
+	ActorName string
+	PostID    *uint
+	CommentID *uint
+	Subject   string
+	Body      string
+	Aggregate bool // Merge into the recipient's unread notification of the same type for the same post
+	Anonymous bool // The content was posted anonymously
+}
+
+// Send stores a notification for e and reports whether it did. Nothing is
+// sent when the recipient is the actor, has switched the type off, has
+// blocked or muted the actor (or been blocked by them), or the actor is
+// shadowbanned; moderation notices are always sent. Blocks and mutes do not
+// apply to anonymous content, like in listings, otherwise a missing
+// notification would reveal who wrote it. Notifications
+// are best effort: failures are logged, not returned, so that they never
+// undo the action that caused them.
+func Send(db *gorm.DB, e Event) bool {
+	if e.UserID == 0 || (e.UserID == e.ActorID && e.Type != models.NotifyModeration) {
+		return false
+	}
+	if e.Type != models.NotifyModeration {
+		if !Enabled(db, e.UserID, e.Type) || visibility.Shadowbanned(db, e.ActorID) {
+			return false
+		}
+		if !e.Anonymous && (visibility.Blocked(db, e.UserID, e.ActorID) || muted(db, e.UserID, e.ActorID)) {
+			return false
+		}
+	}
+	notification, created, err := send(db, e)
+	if err == nil && e.Type == models.NotifyLike && e.Aggregate {
+		// Count the likers rather than the like events, which include unlikes
+		// and likes again
+		var kept bool
+		if kept, err = recountLikes(db, notification); err == nil && !kept {
+			return false
+		}
+	}
+	if err != nil {
+		log.Printf("Warning: failed to notify user %d of %s: %v", e.UserID, e.Type, err)
+		return false
+	}
+	realtime.Publish(realtime.UserTopic(e.UserID), realtime.EventNotification, notification, 0)
+	// Replies merged into an unread notification were already emailed about
+	if created && e.Type == models.NotifyReply {
+		emailReply(db, notification)
+	}
+	return true
+}
+
+// send stores the notification and returns it as the recipient now sees it.
+// created is false when it was merged into an unread notification.
+func send(db *gorm.DB, e Event) (*models.Notification, bool, error) {
+	notification := models.Notification{
+		UserID:      e.UserID,
+		Type:        e.Type,
+		ActorID:     e.ActorID,
+		ActorName:   e.ActorName,
+		IsAnonymous: e.Anonymous,
+		PostID:      e.PostID,
+		CommentID:   e.CommentID,
+		Subject:     e.Subject,
+		Body:        e.Body,
+	}
+
+	if e.Aggregate && e.PostID != nil {
+		notification.GroupKey = fmt.Sprintf("%s:post:%d", e.Type, *e.PostID)
+		// The same actor toggling repeatedly does not count twice in a row
+		result := db.Model(&models.Notification{}).
+			Where("user_id = ? AND group_key = ? AND read_at IS NULL", e.UserID, notification.GroupKey).
+			Updates(map[string]interface{}{
+				"actor_count":  gorm.Expr("actor_count + CASE WHEN actor_id = ? THEN 0 ELSE 1 END", e.ActorID),
+				"actor_id":     e.ActorID,
+				"actor_name":   e.ActorName,
+				"is_anonymous": e.Anonymous,
+				"comment_id":   e.CommentID,
+				"body":         e.Body,
+				"updated_at":   time.Now(),
+			})
+		if result.Error != nil {
+			return nil, false, result.Error
+		}
+		if result.RowsAffected > 0 {
+			err := db.Where("user_id = ? AND group_key = ? AND read_at IS NULL", e.UserID, notification.GroupKey).
+				First(&notification).Error
+			return &notification, false, err
+		}
+	}
+	if err := db.Create(&notification).Error; err != nil {
+		return nil, false, err
+	}
+	notification.Message = notification.Summary()
+	return &notification, true, nil
+}
+
+// likeGroupKey is the GroupKey of aggregated like notifications for a post
+func likeGroupKey(postID uint) string {
+	return fmt.Sprintf("%s:post:%d", models.NotifyLike, postID) // As built by send
+}
+
+// RecountLikes brings the user's unread like notification for the post, if
+// any, in line with the post's likes after someone unliked it
+func RecountLikes(db *gorm.DB, userID, postID uint) {
+	var notification models.Notification
+	err := db.Where("user_id = ? AND group_key = ? AND read_at IS NULL", userID, likeGroupKey(postID)).
+		First(&notification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err == nil {
+		_, err = recountLikes(db, &notification)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to recount like notification of user %d for post %d: %v", userID, postID, err)
+	}
+}
+
+// recountLikes sets the actors of an unread like notification from the
+// likes themselves: the users the recipient would hear about who liked the
+// post since the previous like notification for it was read, newest first.
+// When none of them still like it the notification is deleted and
+// *notification is left as it was; the boolean reports whether it remains.
+func recountLikes(db *gorm.DB, notification *models.Notification) (bool, error) {
+	query := db.Model(&models.PostLike{}).
+		Scopes(visibility.Viewer{UserID: notification.UserID}.OthersLikes).
+		Joins("JOIN users ON users.id = post_likes.user_id").
+		Where("post_likes.post_id = ?", notification.PostID)
+	var previous models.Notification
+	err := db.Where("user_id = ? AND group_key = ? AND read_at IS NOT NULL", notification.UserID, notification.GroupKey).
+		Order("updated_at desc").First(&previous).Error
+	if err == nil {
+		query = query.Where("post_likes.created_at > ?", previous.UpdatedAt)
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	var likers []struct {
+		UserID   uint
+		Username string
+	}
+	err = query.Select("post_likes.user_id, users.username").Order("post_likes.created_at desc").Scan(&likers).Error
+	if err != nil {
+		return false, err
+	}
+	if len(likers) == 0 {
+		return false, db.Delete(notification).Error
+	}
+	err = db.Model(notification).Updates(map[string]interface{}{
+		"actor_count": len(likers),
+		"actor_id":    likers[0].UserID,
+		"actor_name":  likers[0].Username,
+	}).Error
+	notification.Message = notification.Summary()
+	return true, err
+}
+
+// Enabled reports whether the user wants notifications of type notificationType
+func Enabled(db *gorm.DB, userID uint, notificationType string) bool {
+	var disabled int64
+	db.Model(&models.NotificationPreference{}).
+		Where("user_id = ? AND type = ? AND enabled = ?", userID, notificationType, false).
+		Count(&disabled)
+	return disabled == 0
+}
+
+func muted(db *gorm.DB, userID, actorID uint) bool {
+	var count int64
+	db.Model(&models.UserMute{}).Where("user_id = ? AND muted_id = ?", userID, actorID).Count(&count)
+	return count > 0
+}
+
+// Excerpt shortens text for a notification body
+func Excerpt(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,195 @@
+// Package policy checks new posts and comments against the site's content
+// rules before they are stored: moderator-defined word filters, link limits,
+// duplicate detection and any registered classifiers.
+package policy
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/models"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+)
+
+// minDuplicateLength keeps short replies like "thanks!" out of duplicate detection
+const minDuplicateLength = 20
+
+var linkPattern = regexp.MustCompile(`(?i)\bhttps?://|\bwww\.`)
+
+// Input is the content being checked
+type Input struct {
+	Kind       string // models.ReportTargetPost or models.ReportTargetComment
+	ID         uint   // The post or comment being rechecked, 0 for new content
+	Section    string
+	AuthorID   uint
+	AccountAge time.Duration
+	Title      string // Empty for comments
+	Content    string
+}
+
+// Result is the outcome of Check. Title and Content have masked words
+// replaced; Reasons explains why the content was blocked or flagged.
+type Result struct {
+	Title       string
+	Content     string
+	Fingerprint string
+	Blocked     bool
+	Flagged     bool
+	Reasons     []string
+}
+
+func (r *Result) apply(action, reason string) {
+	switch action {
+	case models.FilterActionBlock:
+		r.Blocked = true
+	case models.FilterActionFlag:
+		r.Flagged = true
+	default:
+		return
+	}
+	r.Reasons = append(r.Reasons, reason)
+}
+
+// Classifier is a hook for external checks such as a spam or toxicity
+// service. It returns models.FilterActionBlock, models.FilterActionFlag or an
+// empty action to let the content through.
+type Classifier interface {
+	Classify(ctx context.Context, in Input) (action, reason string, err error)
+}
+
+var (
+	classifiersMu sync.RWMutex
+	classifiers   []Classifier
+)
+
+// RegisterClassifier adds a classifier to every future Check
+func RegisterClassifier(c Classifier) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+	classifiers = append(classifiers, c)
+}
+
+// Check runs the content policy. Classifier errors are logged and ignored so
+// that an outage of an external service does not stop people from posting.
+func Check(ctx context.Context, db *gorm.DB, in Input, now time.Time) (*Result, error) {
+	result := &Result{
+		Title:       in.Title,
+		Content:     in.Content,
+		Fingerprint: Fingerprint(in.Title, in.Content),
+	}
+
+	if err := applyFilters(db, in.Section, result); err != nil {
+		return nil, err
+	}
+
+	maxLinks := config.MaxLinks
+	if in.AccountAge < time.Duration(config.NewAccountDays)*24*time.Hour {
+		maxLinks = config.NewAccountMaxLinks
+	}
+	if links := len(linkPattern.FindAllStringIndex(in.Title+"\n"+in.Content, -1)); links > maxLinks {
+		result.apply(models.FilterActionFlag, fmt.Sprintf("contains %d links, the limit is %d", links, maxLinks))
+	}
+
+	if err := checkDuplicate(db, in, result, now); err != nil {
+		return nil, err
+	}
+
+	classifiersMu.RLock()
+	hooks := classifiers
+	classifiersMu.RUnlock()
+	for _, c := range hooks {
+		action, reason, err := c.Classify(ctx, in)
+		if err != nil {
+			log.Printf("Warning: content classifier %T failed: %v", c, err)
+			continue
+		}
+		result.apply(action, reason)
+	}
+	return result, nil
+}
+
+// CompileFilter turns a filter into a case-insensitive regular expression.
+// Plain patterns match whole words only.
+func CompileFilter(f models.ContentFilter) (*regexp.Regexp, error) {
+	if f.IsRegex {
+		return regexp.Compile("(?i)" + f.Pattern)
+	}
+	return regexp.Compile(`(?i)\b` + regexp.QuoteMeta(f.Pattern) + `\b`)
+}
+
+func applyFilters(db *gorm.DB, section string, result *Result) error {
+	var filters []models.ContentFilter
+	if err := db.Where("section = '' OR section = ?", section).Order("id asc").Find(&filters).Error; err != nil {
+		return err
+	}
+
+	for _, f := range filters {
+		re, err := CompileFilter(f)
+		if err != nil {
+			log.Printf("Warning: skipping content filter %d: %v", f.ID, err)
+			continue
+		}
+		if !re.MatchString(result.Title) && !re.MatchString(result.Content) {
+			continue
+		}
+		if f.Action == models.FilterActionMask {
+			result.Title = re.ReplaceAllStringFunc(result.Title, mask)
+			result.Content = re.ReplaceAllStringFunc(result.Content, mask)
+			continue
+		}
+		result.apply(f.Action, fmt.Sprintf("matched content filter #%d", f.ID))
+	}
+	return nil
+}
+
+func mask(s string) string {
+	return strings.Repeat("*", utf8.RuneCountInString(s))
+}
+
+// checkDuplicate compares the content with recent posts or comments. Reposting
+// your own content is blocked, copying someone else's is flagged.
+func checkDuplicate(db *gorm.DB, in Input, result *Result, now time.Time) error {
+	if utf8.RuneCountInString(strings.TrimSpace(in.Content)) < minDuplicateLength {
+		return nil
+	}
+
+	var authorIDs []uint
+	query := db.Model(&models.Post{})
+	if in.Kind == models.ReportTargetComment {
+		query = db.Model(&models.Comment{})
+	}
+	since := now.Add(-time.Duration(config.DuplicateWindowHours) * time.Hour)
+	err := query.Where("content_hash = ? AND created_at > ? AND id <> ?", result.Fingerprint, since, in.ID).
+		Distinct().Pluck("author_id", &authorIDs).Error
+	if err != nil {
+		return err
+	}
+
+	for _, id := range authorIDs {
+		if id == in.AuthorID {
+			result.apply(models.FilterActionBlock, fmt.Sprintf("you already posted this in the last %d hours", config.DuplicateWindowHours))
+			return nil
+		}
+	}
+	if len(authorIDs) > 0 {
+		result.apply(models.FilterActionFlag, "duplicates recent content by another user")
+	}
+	return nil
+}
+
+// Fingerprint hashes content after normalizing case and whitespace, so that
+// trivially edited copies still match
+func Fingerprint(title, content string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(title+" "+content), " "))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
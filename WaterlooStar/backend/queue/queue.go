@@ -0,0 +1,174 @@
+// Package queue runs background work that must survive restarts, such as
+// sending email. Jobs are rows in the jobs table; every backend instance can
+// run the worker, and each job is claimed by one instance at a time.
+package queue
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lease is how long a claimed job is reserved. Jobs of an instance that dies
+// mid-run are retried once it expires.
+const lease = 5 * time.Minute
+
+// batchSize is how many due jobs one run claims
+const batchSize = 20
+
+// retention is how long finished jobs are kept for inspection
+const retention = 7 * 24 * time.Hour
+
+// Handler runs one job. Returning an error retries the job with exponential
+// backoff until it runs out of attempts.
+type Handler func(payload []byte) error
+
+var (
+	mu       sync.RWMutex
+	handlers = make(map[string]Handler)
+)
+
+// Register sets the handler for a queue. Packages register their queues in
+// init so that every instance can run every job.
+func Register(queue string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[queue] = handler
+}
+
+// Enqueue adds a job to run as soon as possible. Pass the transaction of the
+// action that causes the job so that both happen or neither does.
+func Enqueue(db *gorm.DB, queue string, payload interface{}) error {
+	return EnqueueAt(db, queue, payload, time.Now())
+}
+
+// EnqueueAt adds a job to run at runAt
+func EnqueueAt(db *gorm.DB, queue string, payload interface{}, runAt time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return db.Create(&models.Job{Queue: queue, Payload: string(data), RunAt: runAt, MaxAttempts: 5}).Error
+}
+
+// Backoff is the delay before retrying a job that failed attempts times:
+// one minute, doubling up to six hours
+func Backoff(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+	return delay
+}
+
+// claim reserves up to batchSize due jobs. SKIP LOCKED lets several
+// instances claim at once without taking the same job.
+func claim() ([]models.Job, error) {
+	mu.RLock()
+	queues := make([]string, 0, len(handlers))
+	for queue := range handlers {
+		queues = append(queues, queue)
+	}
+	mu.RUnlock()
+	if len(queues) == 0 {
+		return nil, nil
+	}
+
+	var jobs []models.Job
+	err := storage.DB.Raw(`
+		UPDATE jobs SET attempts = attempts + 1, run_at = ?, updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE done_at IS NULL AND failed_at IS NULL AND run_at <= NOW() AND queue IN ?
+			ORDER BY run_at
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		time.Now().Add(lease), queues, batchSize,
+	).Scan(&jobs).Error
+	return jobs, err
+}
+
+// RunDue runs the jobs that are due and returns how many succeeded and failed
+func RunDue() (succeeded, failed int, err error) {
+	jobs, err := claim()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, job := range jobs {
+		if runErr := run(job); runErr != nil {
+			failed++
+			finish(job, runErr)
+			continue
+		}
+		succeeded++
+		finish(job, nil)
+	}
+	return succeeded, failed, nil
+}
+
+func run(job models.Job) (err error) {
+	mu.RLock()
+	handler, ok := handlers[job.Queue]
+	mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no handler for queue %s", job.Queue)
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return handler([]byte(job.Payload))
+}
+
+// finish records the outcome of an attempt
+func finish(job models.Job, runErr error) {
+	now := time.Now()
+	updates := map[string]interface{}{"updated_at": now}
+	switch {
+	case runErr == nil:
+		updates["done_at"] = now
+		updates["last_error"] = ""
+	case job.Attempts >= job.MaxAttempts:
+		updates["failed_at"] = now
+		updates["last_error"] = runErr.Error()
+		log.Printf("Queue: job %d (%s) failed for good after %d attempts: %v", job.ID, job.Queue, job.Attempts, runErr)
+	default:
+		updates["run_at"] = now.Add(Backoff(job.Attempts))
+		updates["last_error"] = runErr.Error()
+	}
+	if err := storage.DB.Model(&models.Job{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		log.Printf("Queue: failed to record outcome of job %d: %v", job.ID, err)
+	}
+}
+
+// Start runs due jobs every interval in the background and clears out
+// finished jobs after a week
+func Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			succeeded, failed, err := RunDue()
+			if err != nil {
+				log.Printf("Queue: failed to claim jobs: %v", err)
+				continue
+			}
+			if succeeded+failed > 0 {
+				log.Printf("📬 Queue: ran %d jobs, %d failed", succeeded+failed, failed)
+			}
+			storage.DB.Where("done_at < ?", time.Now().Add(-retention)).Delete(&models.Job{})
+		}
+	}()
+}
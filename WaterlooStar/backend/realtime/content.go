@@ -0,0 +1,94 @@
+package realtime
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"time"
+)
+
+// LikeCount is the data of a post.likes event
+type LikeCount struct {
+	PostID uint `json:"post_id"`
+	Likes  uint `json:"likes"`
+}
+
+// authorFilterID is the author ID used for block and mute filtering.
+// Anonymous content is not filtered, just like in the read paths.
+func authorFilterID(authorID uint, anonymous bool) uint {
+	if anonymous {
+		return 0
+	}
+	return authorID
+}
+
+// PublishPost announces a newly published post to its section. Posts that
+// are hidden or written by shadowbanned users are not announced.
+func PublishPost(post *models.Post) {
+	if !post.IsVisible() || visibility.Shadowbanned(storage.DB, post.AuthorID) {
+		return
+	}
+	Publish(SectionTopic(post.Section), EventPostCreated, post, authorFilterID(post.AuthorID, post.IsAnonymous))
+}
+
+// PublishComment announces a new comment to the post's subscribers
+func PublishComment(comment *models.Comment) {
+	if comment.IsHidden || visibility.Shadowbanned(storage.DB, comment.AuthorID) {
+		return
+	}
+	Publish(PostTopic(comment.PostID), EventCommentCreated, comment, authorFilterID(comment.AuthorID, comment.IsAnonymous))
+}
+
+// PublishLikes announces a post's like count after likerID liked or unliked
+// it. The count is the one guests see, without likes by shadowbanned users.
+func PublishLikes(postID uint, likes uint, likerID uint) {
+	if visibility.Shadowbanned(storage.DB, likerID) {
+		return
+	}
+	posts := []models.Post{{ID: postID, Likes: likes}}
+	visibility.Viewer{}.AdjustLikeCounts(storage.DB, posts)
+	Publish(PostTopic(postID), EventPostLikes, LikeCount{PostID: postID, Likes: posts[0].Likes}, 0)
+}
+
+// MessageEvent is the data of a message.created event. Muted is set when the
+// recipient muted the conversation, so clients can skip alerting.
+type MessageEvent struct {
+	Message *models.Message `json:"message"`
+	Muted   bool            `json:"muted,omitempty"`
+}
+
+// ReadReceipt is the data of a message.read event
+type ReadReceipt struct {
+	ConversationID    uint      `json:"conversation_id"`
+	UserID            uint      `json:"user_id"`
+	LastReadMessageID uint      `json:"last_read_message_id"`
+	ReadAt            time.Time `json:"read_at"`
+}
+
+// PublishMessage delivers a direct message to the streams of the members who
+// may read it, see visibility.Viewer.Messages. muted lists the members who
+// muted the conversation.
+func PublishMessage(message *models.Message, memberIDs []uint, muted map[uint]bool) {
+	shadowbanned := visibility.Shadowbanned(storage.DB, message.SenderID)
+	for _, userID := range memberIDs {
+		if userID != message.SenderID {
+			recipient := visibility.Viewer{UserID: userID}
+			if shadowbanned || recipient.UnwantedAuthors(storage.DB)[message.SenderID] {
+				continue
+			}
+		}
+		Publish(UserTopic(userID), EventMessageCreated, MessageEvent{Message: message, Muted: muted[userID]}, message.SenderID)
+	}
+}
+
+// PublishReadReceipt tells the other members that a member read up to a message
+func PublishReadReceipt(receipt ReadReceipt, memberIDs []uint) {
+	if visibility.Shadowbanned(storage.DB, receipt.UserID) {
+		return
+	}
+	for _, userID := range memberIDs {
+		if userID != receipt.UserID {
+			Publish(UserTopic(userID), EventMessageRead, receipt, receipt.UserID)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+// Package realtime fans live events out to connected clients. Handlers
+// publish to topics, the hub hands every event to a Backend (in-process, or
+// Postgres LISTEN/NOTIFY when several instances run) and delivers what comes
+// back to the local subscribers of each topic.
+package realtime
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// historySize is how many recent events are kept for Last-Event-ID resume
+const historySize = 1000
+
+// subscriptionBuffer is how many events a subscriber may fall behind before
+// it is dropped. Dropped clients reconnect and resume with Last-Event-ID.
+const subscriptionBuffer = 64
+
+// Event types
+const (
+	EventPostCreated    = "post.created"
+	EventCommentCreated = "comment.created"
+	EventPostLikes      = "post.likes"
+	EventNotification   = "notification"
+	EventMessageCreated = "message.created"
+	EventMessageRead    = "message.read"
+	EventPresence       = "presence"
+	EventTyping         = "typing"
+)
+
+// SectionTopic carries new posts in a section
+func SectionTopic(slug string) string { return "section:" + slug }
+
+// PostTopic carries new comments and like counts of a post
+func PostTopic(postID uint) string { return fmt.Sprintf("post:%d", postID) }
+
+// UserTopic carries a user's own notifications and direct messages
+func UserTopic(userID uint) string { return fmt.Sprintf("user:%d", userID) }
+
+// Event is one published update. IDs increase over time, also across
+// instances, so they can be used to resume.
+type Event struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	Truncated bool            `json:"truncated,omitempty"` // Data was too large for the backend, refetch instead
+	AuthorID  uint            `json:"author_id,omitempty"` // Used for block and mute filtering, 0 for anonymous content
+	Ephemeral bool            `json:"ephemeral,omitempty"` // Not kept for resume and skipped for subscribers that fall behind
+}
+
+// Backend carries events between instances. Publish sends an event to every
+// instance, including this one, which receives it through the deliver
+// function passed to Start.
+type Backend interface {
+	Start(deliver func(Event)) error
+	Publish(e Event) error
+}
+
+// LocalBackend delivers events within this process only
+type LocalBackend struct {
+	deliver func(Event)
+}
+
+func (b *LocalBackend) Start(deliver func(Event)) error {
+	b.deliver = deliver
+	return nil
+}
+
+func (b *LocalBackend) Publish(e Event) error {
+	b.deliver(e)
+	return nil
+}
+
+// Subscription receives the events of its topics on C. C is closed when the
+// subscriber falls too far behind or unsubscribes.
+type Subscription struct {
+	C      chan Event
+	topics map[string]bool
+	filter func(Event) bool
+	closed bool
+}
+
+// Hub tracks the subscribers of this instance
+type Hub struct {
+	backend  Backend
+	instance string // Identifies this instance in presence updates
+	presence presence
+
+	mu      sync.Mutex
+	subs    map[*Subscription]struct{}
+	history []Event
+	lastID  int64
+}
+
+// NewHub starts a hub on backend
+func NewHub(backend Backend) (*Hub, error) {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	h := &Hub{
+		backend:  backend,
+		instance: hex.EncodeToString(buf),
+		presence: newPresence(),
+		subs:     make(map[*Subscription]struct{}),
+	}
+	if err := backend.Start(h.deliver); err != nil {
+		return nil, err
+	}
+	go h.refreshPresence(presenceRefresh)
+	return h, nil
+}
+
+// Default is the hub used by Publish. It is nil until Init runs, and
+// publishing to a nil hub does nothing.
+var Default *Hub
+
+// Init sets up Default: backend "postgres" uses LISTEN/NOTIFY on the database
+// at dsn, anything else stays in-process
+func Init(backend, dsn string) {
+	var b Backend = &LocalBackend{}
+	if backend == "postgres" {
+		b = &PostgresBackend{DSN: dsn}
+	}
+	hub, err := NewHub(b)
+	if err != nil {
+		log.Fatalf("Failed to start realtime hub: %v", err)
+	}
+	Default = hub
+	log.Printf("Realtime events use the %T", b)
+}
+
+// Publish sends an event to all subscribers of topic on every instance.
+// data is encoded as JSON. Failures are logged; live updates are best effort.
+func Publish(topic, eventType string, data interface{}, authorID uint) {
+	if Default == nil {
+		return
+	}
+	Default.Publish(topic, eventType, data, authorID)
+}
+
+// PublishEphemeral is Publish for short-lived events such as typing
+// indicators, which are not replayed and may be dropped under load
+func PublishEphemeral(topic, eventType string, data interface{}, authorID uint) {
+	if Default == nil {
+		return
+	}
+	Default.publish(topic, eventType, data, authorID, true)
+}
+
+func (h *Hub) Publish(topic, eventType string, data interface{}, authorID uint) {
+	h.publish(topic, eventType, data, authorID, false)
+}
+
+func (h *Hub) publish(topic, eventType string, data interface{}, authorID uint, ephemeral bool) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s event: %v", eventType, err)
+		return
+	}
+	e := Event{ID: h.nextID(), Topic: topic, Type: eventType, Data: raw, AuthorID: authorID, Ephemeral: ephemeral}
+	if err := h.backend.Publish(e); err != nil {
+		log.Printf("Warning: failed to publish %s event to %s: %v", eventType, topic, err)
+	}
+}
+
+// nextID returns a time-based ID that increases on this instance
+func (h *Hub) nextID() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	id := time.Now().UnixMicro()
+	if id <= h.lastID {
+		id = h.lastID + 1
+	}
+	h.lastID = id
+	return id
+}
+
+// Subscribe registers for topics. filter, if set, drops events the
+// subscriber must not see. Events after lastEventID that are still in the
+// history are returned for replay; pass 0 to skip replay.
+func (h *Hub) Subscribe(topics []string, filter func(Event) bool, lastEventID int64) (*Subscription, []Event) {
+	sub := &Subscription{
+		C:      make(chan Event, subscriptionBuffer),
+		topics: make(map[string]bool, len(topics)),
+		filter: filter,
+	}
+	for _, topic := range topics {
+		sub.topics[topic] = true
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var replay []Event
+	if lastEventID > 0 {
+		for _, e := range h.history {
+			if e.ID > lastEventID && sub.wants(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+	h.subs[sub] = struct{}{}
+	return sub, replay
+}
+
+// AddTopic subscribes sub to one more topic. Like Subscribe, it returns the
+// events of that topic after lastEventID that are still in the history.
+func (h *Hub) AddTopic(sub *Subscription, topic string, lastEventID int64) []Event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if sub.closed {
+		return nil
+	}
+	sub.topics[topic] = true
+	var replay []Event
+	if lastEventID > 0 {
+		for _, e := range h.history {
+			if e.ID > lastEventID && e.Topic == topic && sub.wants(e) {
+				replay = append(replay, e)
+			}
+		}
+	}
+	return replay
+}
+
+// RemoveTopic stops delivery of topic to sub
+func (h *Hub) RemoveTopic(sub *Subscription, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(sub.topics, topic)
+}
+
+// Unsubscribe stops delivery and closes sub.C
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drop(sub)
+}
+
+func (h *Hub) drop(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(h.subs, sub)
+	close(sub.C)
+}
+
+func (s *Subscription) wants(e Event) bool {
+	return s.topics[e.Topic] && (s.filter == nil || s.filter(e))
+}
+
+// deliver records an event from the backend and fans it out locally.
+// Presence updates from the instances are merged into one presence event.
+func (h *Hub) deliver(e Event) {
+	if e.Type == eventPresenceSync {
+		var ok bool
+		if e, ok = h.syncPresence(e); !ok {
+			return
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e.ID > h.lastID {
+		h.lastID = e.ID
+	}
+	if !e.Ephemeral {
+		h.history = append(h.history, e)
+		if len(h.history) > historySize {
+			h.history = h.history[len(h.history)-historySize:]
+		}
+	}
+
+	for sub := range h.subs {
+		if !sub.wants(e) {
+			continue
+		}
+		select {
+		case sub.C <- e:
+		default:
+			// Losing an ephemeral event is fine; anything else means the
+			// subscriber has to resume from its last event ID
+			if !e.Ephemeral {
+				h.drop(sub)
+			}
+		}
+	}
+}
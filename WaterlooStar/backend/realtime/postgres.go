@@ -0,0 +1,96 @@
+package realtime
+
+import (
+	"WaterlooStar/backend/storage"
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	notifyChannel = "waterloostar_events"
+	// maxNotifyPayload stays below Postgres' 8000 byte NOTIFY limit
+	maxNotifyPayload = 7900
+)
+
+// PostgresBackend shares events between instances with LISTEN/NOTIFY. Each
+// instance keeps one dedicated connection for LISTEN and reconnects if it drops.
+type PostgresBackend struct {
+	DSN string
+}
+
+func (b *PostgresBackend) Start(deliver func(Event)) error {
+	conn, err := b.listen()
+	if err != nil {
+		return err
+	}
+	go b.run(conn, deliver)
+	return nil
+}
+
+func (b *PostgresBackend) listen() (*pgx.Conn, error) {
+	ctx := context.Background()
+	conn, err := pgx.Connect(ctx, b.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		conn.Close(ctx)
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (b *PostgresBackend) run(conn *pgx.Conn, deliver func(Event)) {
+	ctx := context.Background()
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			log.Printf("Realtime: lost LISTEN connection: %v", err)
+			conn.Close(ctx)
+			conn = b.reconnect()
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(notification.Payload), &e); err != nil {
+			log.Printf("Realtime: ignoring malformed event: %v", err)
+			continue
+		}
+		deliver(e)
+	}
+}
+
+func (b *PostgresBackend) reconnect() *pgx.Conn {
+	delay := time.Second
+	for {
+		time.Sleep(delay)
+		conn, err := b.listen()
+		if err == nil {
+			log.Println("Realtime: LISTEN connection restored")
+			return conn
+		}
+		log.Printf("Realtime: reconnect failed: %v", err)
+		if delay < time.Minute {
+			delay *= 2
+		}
+	}
+}
+
+// Publish sends the event with pg_notify. Events too large for NOTIFY are
+// sent without their data and marked truncated.
+func (b *PostgresBackend) Publish(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		e.Data, e.Truncated = nil, true
+		if payload, err = json.Marshal(e); err != nil {
+			return err
+		}
+	}
+	return storage.DB.Exec("SELECT pg_notify(?, ?)", notifyChannel, string(payload)).Error
+}
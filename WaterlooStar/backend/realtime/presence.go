@@ -0,0 +1,163 @@
+package realtime
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// eventPresenceSync carries one instance's viewer count of a topic. It is
+// turned into an EventPresence with the total before reaching subscribers.
+const eventPresenceSync = "presence.sync"
+
+// presenceRefresh is how often each instance re-announces its viewers.
+// Counts of instances that stop announcing expire after presenceExpiry.
+const (
+	presenceRefresh = 30 * time.Second
+	presenceExpiry  = 3 * presenceRefresh
+)
+
+// Presence is the data of a presence event
+type Presence struct {
+	Viewing int `json:"viewing"`
+}
+
+type presenceSync struct {
+	Instance string `json:"instance"`
+	Viewers  int    `json:"viewers"`
+}
+
+type instanceViewers struct {
+	viewers int
+	seen    time.Time
+}
+
+// presence counts the users viewing each topic. Users are counted once per
+// instance, however many connections they have open.
+type presence struct {
+	mu        sync.Mutex
+	local     map[string]map[uint]int               // topic -> user -> connections on this instance
+	instances map[string]map[string]instanceViewers // topic -> instance -> last announced count
+}
+
+func newPresence() presence {
+	return presence{
+		local:     make(map[string]map[uint]int),
+		instances: make(map[string]map[string]instanceViewers),
+	}
+}
+
+// total sums the counts of all instances that announced recently. Call with p.mu held.
+func (p *presence) total(topic string, now time.Time) int {
+	total := 0
+	for instance, count := range p.instances[topic] {
+		if now.Sub(count.seen) > presenceExpiry {
+			delete(p.instances[topic], instance)
+			continue
+		}
+		total += count.viewers
+	}
+	if len(p.instances[topic]) == 0 {
+		delete(p.instances, topic)
+	}
+	return total
+}
+
+// Join counts userID as viewing topic until the matching Leave
+func (h *Hub) Join(topic string, userID uint) {
+	p := &h.presence
+	p.mu.Lock()
+	users := p.local[topic]
+	if users == nil {
+		users = make(map[uint]int)
+		p.local[topic] = users
+	}
+	users[userID]++
+	changed, viewers := users[userID] == 1, len(users)
+	p.mu.Unlock()
+
+	if changed {
+		h.announcePresence(topic, viewers)
+	}
+}
+
+// Leave undoes one Join
+func (h *Hub) Leave(topic string, userID uint) {
+	p := &h.presence
+	p.mu.Lock()
+	users := p.local[topic]
+	if users == nil || users[userID] == 0 {
+		p.mu.Unlock()
+		return
+	}
+	users[userID]--
+	changed := users[userID] == 0
+	if changed {
+		delete(users, userID)
+	}
+	viewers := len(users)
+	if viewers == 0 {
+		delete(p.local, topic)
+	}
+	p.mu.Unlock()
+
+	if changed {
+		h.announcePresence(topic, viewers)
+	}
+}
+
+// Viewing returns how many users are viewing topic across all instances
+func (h *Hub) Viewing(topic string) int {
+	h.presence.mu.Lock()
+	defer h.presence.mu.Unlock()
+	return h.presence.total(topic, time.Now())
+}
+
+func (h *Hub) announcePresence(topic string, viewers int) {
+	h.publish(topic, eventPresenceSync, presenceSync{Instance: h.instance, Viewers: viewers}, 0, true)
+}
+
+// refreshPresence re-announces this instance's viewers so that other
+// instances keep counting them
+func (h *Hub) refreshPresence(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.presence.mu.Lock()
+		counts := make(map[string]int, len(h.presence.local))
+		for topic, users := range h.presence.local {
+			counts[topic] = len(users)
+		}
+		h.presence.mu.Unlock()
+
+		for topic, viewers := range counts {
+			h.announcePresence(topic, viewers)
+		}
+	}
+}
+
+// syncPresence records an instance's count and returns the presence event
+// for local subscribers. Refreshes that do not change the total return false.
+func (h *Hub) syncPresence(e Event) (Event, bool) {
+	var update presenceSync
+	if err := json.Unmarshal(e.Data, &update); err != nil {
+		return e, false
+	}
+
+	p := &h.presence
+	now := time.Now()
+	p.mu.Lock()
+	before := p.total(e.Topic, now)
+	if p.instances[e.Topic] == nil {
+		p.instances[e.Topic] = make(map[string]instanceViewers)
+	}
+	p.instances[e.Topic][update.Instance] = instanceViewers{viewers: update.Viewers, seen: now}
+	total := p.total(e.Topic, now)
+	p.mu.Unlock()
+
+	if total == before {
+		return e, false
+	}
+	data, _ := json.Marshal(Presence{Viewing: total})
+	return Event{ID: e.ID, Topic: e.Topic, Type: EventPresence, Data: data, Ephemeral: true}, true
+}
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// Content formats for posts and comments
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// CommonMark plus GFM tables, autolinks and strikethrough. Fenced code blocks
+// are part of CommonMark. Raw HTML is escaped by goldmark by default and the
+// output is sanitized again below, so neither layer has to be trusted alone.
+var markdown = goldmark.New(
+	goldmark.WithExtensions(
+		extension.Table,
+		extension.Linkify,
+		extension.Strikethrough,
+	),
+)
+
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Keep the language hint of fenced code blocks for client-side highlighting
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// ValidFormat reports whether format is a supported content format. The empty
+// string is accepted and treated as plain text.
+func ValidFormat(format string) bool {
+	return format == "" || format == FormatPlain || format == FormatMarkdown
+}
+
+// HTML renders content in the given format into sanitized HTML that is safe to
+// insert into a page as is.
+func HTML(format, content string) string {
+	if content == "" {
+		return ""
+	}
+	if format != FormatMarkdown {
+		return plainHTML(content)
+	}
+
+	var buf bytes.Buffer
+	if err := markdown.Convert([]byte(content), &buf); err != nil {
+		// goldmark only fails on writer errors, fall back to escaped text
+		return plainHTML(content)
+	}
+	return policy.Sanitize(buf.String())
+}
+
+// plainHTML escapes text and keeps its line breaks
+func plainHTML(content string) string {
+	escaped := html.EscapeString(content)
+	return "<p>" + strings.ReplaceAll(escaped, "\n", "<br>\n") + "</p>"
+}
@@ -2,12 +2,38 @@ package storage
 
 import (
 	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/render"
 	"log"
+
+	"gorm.io/gorm"
 )
 
+// AllModels lists every model managed by AutoMigrate
+func AllModels() []interface{} {
+	return []interface{}{
+		&models.User{}, &models.Post{}, &models.Comment{}, &models.PostLike{},
+		&models.Bookmark{}, &models.Attachment{},
+		&models.Poll{}, &models.PollOption{}, &models.PollVote{},
+		&models.Section{}, &models.ThreadPseudonym{},
+		&models.Report{}, &models.ModerationAction{}, &models.Ban{},
+		&models.AuditEntry{}, &models.ContentFilter{}, &models.RateLimitBucket{},
+		&models.UserBlock{}, &models.UserMute{},
+		&models.Notification{}, &models.NotificationPreference{},
+		&models.Mention{}, &models.Job{}, &models.EmailPreference{},
+		&models.Follow{}, &models.FeedCache{}, &models.FeedEntry{},
+		&models.ThreadSubscription{},
+		&models.Conversation{}, &models.ConversationParticipant{}, &models.Message{},
+		&models.Webhook{}, &models.WebhookDelivery{},
+	}
+}
+
 func Migrate() {
 	log.Println("Starting database migration...")
 
+	// Authors of posts from before thread subscriptions are subscribed once,
+	// when the table is created, so that they keep getting reply notifications
+	backfillSubscriptions := !DB.Migrator().HasTable(&models.ThreadSubscription{})
+
 	// Check if we need to run the manual migration first
 	var userCount int64
 	DB.Raw("SELECT COUNT(*) FROM information_schema.tables WHERE table_name = 'users'").Scan(&userCount)
@@ -15,7 +41,7 @@ func Migrate() {
 	if userCount == 0 {
 		log.Println("Users table doesn't exist. Running safe migration...")
 		// Safe migration for new database
-		err := DB.AutoMigrate(&models.User{}, &models.Post{}, &models.Comment{}, &models.PostLike{})
+		err := DB.AutoMigrate(AllModels()...)
 		if err != nil {
 			log.Fatalf("Failed to migrate database: %v", err)
 		}
@@ -32,17 +58,101 @@ func Migrate() {
 		}
 
 		// Safe to migrate normally
-		err := DB.AutoMigrate(&models.User{}, &models.Post{}, &models.Comment{}, &models.PostLike{})
+		err := DB.AutoMigrate(AllModels()...)
 		if err != nil {
 			log.Fatalf("Failed to migrate database: %v", err)
 		}
 	}
 
+	// Unlikes used to soft-delete, which blocked liking the same post again.
+	// Only likes deleted along with their post are worth keeping.
+	err := DB.Exec("DELETE FROM post_likes WHERE deleted_at IS NOT NULL AND post_id IN (SELECT id FROM posts WHERE deleted_at IS NULL)").Error
+	if err != nil {
+		log.Printf("Warning: Failed to clean up unliked post_likes: %v", err)
+	}
+
 	// Add unique constraint for post likes (one like per user per post)
-	err := DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_post_likes_user_post ON post_likes(user_id, post_id)").Error
+	err = DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_post_likes_user_post ON post_likes(user_id, post_id)").Error
 	if err != nil {
 		log.Printf("Warning: Failed to create unique index for post_likes: %v", err)
 	}
 
-	log.Println("Database migrated (tables 'users', 'posts', 'comments', and 'post_likes' ready)")
+	// Posts created before drafts existed are published as of their creation time
+	err = DB.Exec("UPDATE posts SET published_at = created_at WHERE published_at IS NULL AND status = ?", models.PostStatusPublished).Error
+	if err != nil {
+		log.Printf("Warning: Failed to backfill posts.published_at: %v", err)
+	}
+
+	if backfillSubscriptions {
+		err = DB.Exec("INSERT INTO thread_subscriptions (created_at, user_id, post_id) " +
+			"SELECT NOW(), author_id, id FROM posts WHERE author_id <> 0 AND deleted_at IS NULL ON CONFLICT DO NOTHING").Error
+		if err != nil {
+			log.Printf("Warning: Failed to subscribe authors to their posts: %v", err)
+		}
+	}
+
+	// Digest sections used to be an email setting; they are section follows now
+	if DB.Migrator().HasColumn(&models.EmailPreference{}, "sections") {
+		err = DB.Exec("INSERT INTO follows (created_at, user_id, kind, followed_id, name) "+
+			"SELECT NOW(), user_id, ?, 0, btrim(slug) FROM email_preferences, unnest(string_to_array(sections, ',')) AS slug "+
+			"WHERE btrim(slug) <> '' ON CONFLICT DO NOTHING", models.FollowSection).Error
+		if err == nil {
+			err = DB.Migrator().DropColumn(&models.EmailPreference{}, "sections")
+		}
+		if err != nil {
+			log.Printf("Warning: Failed to move digest sections to follows: %v", err)
+		}
+	}
+
+	// Webhook responses are no longer logged
+	if DB.Migrator().HasColumn(&models.WebhookDelivery{}, "response_body") {
+		if err := DB.Migrator().DropColumn(&models.WebhookDelivery{}, "response_body"); err != nil {
+			log.Printf("Warning: Failed to drop webhook_deliveries.response_body: %v", err)
+		}
+	}
+
+	backfillContentHTML()
+	seedSections()
+
+	log.Println("Database migrated (tables 'users', 'posts', 'comments', 'post_likes' and 'bookmarks' ready)")
+}
+
+// backfillContentHTML renders the HTML cache for rows saved before it existed
+func backfillContentHTML() {
+	var posts []models.Post
+	result := DB.Where("content_html IS NULL OR content_html = ''").FindInBatches(&posts, 200, func(_ *gorm.DB, _ int) error {
+		for _, p := range posts {
+			html := render.HTML(p.ContentFormat, p.Content)
+			if err := DB.Model(&models.Post{}).Where("id = ?", p.ID).UpdateColumn("content_html", html).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		log.Printf("Warning: Failed to backfill posts.content_html: %v", result.Error)
+	}
+
+	var comments []models.Comment
+	result = DB.Where("content_html IS NULL OR content_html = ''").FindInBatches(&comments, 200, func(_ *gorm.DB, _ int) error {
+		for _, c := range comments {
+			html := render.HTML(c.ContentFormat, c.Content)
+			if err := DB.Model(&models.Comment{}).Where("id = ?", c.ID).UpdateColumn("content_html", html).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if result.Error != nil {
+		log.Printf("Warning: Failed to backfill comments.content_html: %v", result.Error)
+	}
+}
+
+// seedSections creates the default sections, leaving edited ones untouched
+func seedSections() {
+	for _, section := range models.DefaultSections {
+		if err := DB.Where("slug = ?", section.Slug).FirstOrCreate(&section).Error; err != nil {
+			log.Printf("Warning: Failed to seed section %s: %v", section.Slug, err)
+		}
+	}
 }
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"WaterlooStar/backend/models"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitDecision is the outcome of taking one token from a bucket
+type RateLimitDecision struct {
+	Allowed    bool
+	Remaining  int           // Whole tokens left after this request
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until the next token, when not allowed
+}
+
+// RateLimitStore keeps token buckets. A bucket holds up to capacity tokens
+// and refills at capacity tokens per window.
+type RateLimitStore interface {
+	Take(key string, capacity int, window time.Duration, now time.Time) (RateLimitDecision, error)
+}
+
+var RateLimits RateLimitStore
+
+// InitRateLimitStore selects where token buckets live: "postgres" shares them
+// between instances through the database, anything else keeps them in memory
+func InitRateLimitStore(backend string) {
+	if backend == "postgres" {
+		RateLimits = &PostgresRateLimitStore{DB: DB}
+		log.Println("Keeping rate limits in Postgres")
+		return
+	}
+	RateLimits = NewMemoryRateLimitStore()
+	log.Println("Keeping rate limits in memory")
+}
+
+// takeToken refills a bucket for the time elapsed since updatedAt and takes
+// one token from it if available
+func takeToken(tokens float64, updatedAt time.Time, capacity int, window time.Duration, now time.Time) (float64, RateLimitDecision) {
+	rate := float64(capacity) / window.Seconds() // Tokens per second
+	if elapsed := now.Sub(updatedAt).Seconds(); elapsed > 0 {
+		tokens = math.Min(float64(capacity), tokens+elapsed*rate)
+	}
+
+	var decision RateLimitDecision
+	if tokens >= 1 {
+		tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = time.Duration((1 - tokens) / rate * float64(time.Second))
+	}
+	decision.Remaining = int(tokens)
+	decision.Reset = time.Duration((float64(capacity) - tokens) / rate * float64(time.Second))
+	return tokens, decision
+}
+
+type memoryBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// MemoryRateLimitStore keeps buckets in process memory. Limits are per
+// instance and reset on restart.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*memoryBucket)}
+}
+
+func (s *MemoryRateLimitStore) Take(key string, capacity int, window time.Duration, now time.Time) (RateLimitDecision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bucket, ok := s.buckets[key]
+	if !ok {
+		bucket = &memoryBucket{tokens: float64(capacity), updatedAt: now}
+		s.buckets[key] = bucket
+	}
+	var decision RateLimitDecision
+	bucket.tokens, decision = takeToken(bucket.tokens, bucket.updatedAt, capacity, window, now)
+	bucket.updatedAt = now
+
+	// Every so often drop buckets idle long enough to have refilled
+	s.takes++
+	if s.takes%1024 == 0 {
+		for k, b := range s.buckets {
+			if now.Sub(b.updatedAt) > 24*time.Hour {
+				delete(s.buckets, k)
+			}
+		}
+	}
+	return decision, nil
+}
+
+// PostgresRateLimitStore keeps buckets in the rate_limit_buckets table so
+// that limits hold across instances. Each take locks its bucket row.
+type PostgresRateLimitStore struct {
+	DB *gorm.DB
+}
+
+func (s *PostgresRateLimitStore) Take(key string, capacity int, window time.Duration, now time.Time) (RateLimitDecision, error) {
+	var decision RateLimitDecision
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		bucket := models.RateLimitBucket{Key: key, Tokens: float64(capacity), UpdatedAt: now}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&bucket).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&bucket, "key = ?", key).Error; err != nil {
+			return err
+		}
+		bucket.Tokens, decision = takeToken(bucket.Tokens, bucket.UpdatedAt, capacity, window, now)
+		return tx.Model(&bucket).Updates(map[string]interface{}{"tokens": bucket.Tokens, "updated_at": now}).Error
+	})
+	return decision, err
+}
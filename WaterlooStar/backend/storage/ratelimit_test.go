@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// closeTo compares durations computed through float64 seconds
+func closeTo(got, want time.Duration) bool {
+	return math.Abs(float64(got-want)) < float64(time.Millisecond)
+}
+
+func TestTakeToken(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	// 10 tokens per minute is one token every 6 seconds
+	const capacity = 10
+	const window = time.Minute
+
+	tests := []struct {
+		name       string
+		tokens     float64
+		elapsed    time.Duration
+		wantTokens float64
+		want       RateLimitDecision
+	}{
+		{"full bucket", 10, 0, 9, RateLimitDecision{Allowed: true, Remaining: 9, Reset: 6 * time.Second}},
+		{"last token", 1, 0, 0, RateLimitDecision{Allowed: true, Remaining: 0, Reset: time.Minute}},
+		{"empty bucket", 0, 0, 0, RateLimitDecision{Remaining: 0, Reset: time.Minute, RetryAfter: 6 * time.Second}},
+		{"partly refilled", 0, 3 * time.Second, 0.5, RateLimitDecision{Remaining: 0, Reset: 57 * time.Second, RetryAfter: 3 * time.Second}},
+		{"refilled one token", 0, 6 * time.Second, 0, RateLimitDecision{Allowed: true, Remaining: 0, Reset: time.Minute}},
+		{"fractional remaining rounds down", 3.5, 0, 2.5, RateLimitDecision{Allowed: true, Remaining: 2, Reset: 45 * time.Second}},
+		{"refill capped at capacity", 2, time.Hour, 9, RateLimitDecision{Allowed: true, Remaining: 9, Reset: 6 * time.Second}},
+		{"clock went back", 0, -time.Minute, 0, RateLimitDecision{Remaining: 0, Reset: time.Minute, RetryAfter: 6 * time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens, got := takeToken(tt.tokens, now.Add(-tt.elapsed), capacity, window, now)
+			if math.Abs(tokens-tt.wantTokens) > 1e-9 {
+				t.Errorf("tokens = %v, want %v", tokens, tt.wantTokens)
+			}
+			if got.Allowed != tt.want.Allowed || got.Remaining != tt.want.Remaining ||
+				!closeTo(got.Reset, tt.want.Reset) || !closeTo(got.RetryAfter, tt.want.RetryAfter) {
+				t.Errorf("decision = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryRateLimitStore()
+
+	// A burst of 3 per 30 seconds, then one token every 10 seconds
+	steps := []struct {
+		key     string
+		at      time.Duration
+		allowed bool
+	}{
+		{"a", 0, true},
+		{"a", 0, true},
+		{"a", 0, true},
+		{"a", 0, false},
+		{"b", 0, true}, // Keys have their own buckets
+		{"a", 5 * time.Second, false},
+		{"a", 10 * time.Second, true},
+		{"a", 10 * time.Second, false},
+		{"a", 5 * time.Minute, true},
+		{"a", 5 * time.Minute, true},
+		{"a", 5 * time.Minute, true},
+		{"a", 5 * time.Minute, false},
+	}
+	for i, step := range steps {
+		decision, err := store.Take(step.key, 3, 30*time.Second, start.Add(step.at))
+		if err != nil {
+			t.Fatalf("step %d: Take error = %v", i, err)
+		}
+		if decision.Allowed != step.allowed {
+			t.Errorf("step %d: Take(%q) at %v allowed = %v, want %v", i, step.key, step.at, decision.Allowed, step.allowed)
+		}
+	}
+}
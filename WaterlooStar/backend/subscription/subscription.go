@@ -0,0 +1,77 @@
+// Package subscription keeps track of who watches which threads and tells
+// subscribers about new comments.
+package subscription
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/notify"
+	"WaterlooStar/backend/visibility"
+	"log"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Subscribe subscribes the user to the post. Subscribing twice is not an error.
+func Subscribe(db *gorm.DB, userID, postID uint) error {
+	if userID == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.ThreadSubscription{UserID: userID, PostID: postID}).Error
+}
+
+// Unsubscribe removes the user's subscription to the post, if any
+func Unsubscribe(db *gorm.DB, userID, postID uint) error {
+	return db.Where("user_id = ? AND post_id = ?", userID, postID).Delete(&models.ThreadSubscription{}).Error
+}
+
+// AutoSubscribes reports whether the user wants to be subscribed to the posts
+// they comment on
+func AutoSubscribes(db *gorm.DB, userID uint) bool {
+	var count int64
+	db.Model(&models.NotificationPreference{}).
+		Where("user_id = ? AND type = ? AND enabled", userID, models.AutoSubscribe).
+		Count(&count)
+	return count > 0
+}
+
+// NotifyComment tells the subscribers of the post about a new comment,
+// except the commenter and the users in skip, who already heard about it
+// from a mention. The post author gets a reply notification, everyone else a
+// thread notification. Subscribers who can no longer see the post are left out.
+func NotifyComment(db *gorm.DB, post *models.Post, comment *models.Comment, skip map[uint]bool) {
+	var userIDs []uint
+	err := db.Model(&models.ThreadSubscription{}).
+		Where("post_id = ? AND user_id <> ?", post.ID, comment.AuthorID).
+		Order("id").Pluck("user_id", &userIDs).Error
+	if err != nil {
+		log.Printf("Warning: failed to load subscribers of post %d: %v", post.ID, err)
+		return
+	}
+
+	event := notify.Event{
+		ActorID:   comment.AuthorID,
+		ActorName: comment.Author,
+		PostID:    &post.ID,
+		CommentID: &comment.ID,
+		Subject:   post.Title,
+		Body:      notify.Excerpt(comment.Content, 140),
+		Aggregate: true,
+		Anonymous: comment.IsAnonymous,
+	}
+	for _, userID := range userIDs {
+		if skip[userID] {
+			continue
+		}
+		if userID != post.AuthorID && !(visibility.Viewer{UserID: userID}).CanSeePost(db, post) {
+			continue
+		}
+		event.UserID = userID
+		event.Type = models.NotifyThread
+		if userID == post.AuthorID {
+			event.Type = models.NotifyReply
+		}
+		notify.Send(db, event)
+	}
+}
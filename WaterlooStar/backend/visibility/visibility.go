@@ -0,0 +1,180 @@
+// Package visibility decides which posts, comments and messages a reader may
+// see. Every read path goes through a Viewer so that drafts, content hidden
+// pending review, content by shadowbanned users and content across blocks
+// and mutes are filtered the same way everywhere. Authors always see their
+// own content, and moderators see everything that is published apart from
+// what their own blocks and mutes hide.
+package visibility
+
+import (
+	"WaterlooStar/backend/middleware"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/storage"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// shadowbannedUsers selects the IDs of shadowbanned users for use in subqueries
+const shadowbannedUsers = "SELECT id FROM users WHERE is_shadowbanned AND deleted_at IS NULL"
+
+// unwantedAuthors selects the users a viewer blocked, was blocked by or muted.
+// It takes the viewer's ID three times.
+const unwantedAuthors = "SELECT blocked_id FROM user_blocks WHERE user_id = ? " +
+	"UNION SELECT user_id FROM user_blocks WHERE blocked_id = ? " +
+	"UNION SELECT muted_id FROM user_mutes WHERE user_id = ?"
+
+// Viewer is the reader a query is filtered for. The zero value is a guest.
+type Viewer struct {
+	UserID      uint
+	IsModerator bool
+}
+
+// FromRequest builds the Viewer for the optional authenticated user of r
+func FromRequest(r *http.Request) Viewer {
+	userClaims, ok := middleware.GetUserFromContext(r)
+	if !ok {
+		return Viewer{}
+	}
+	var user models.User
+	if err := storage.DB.Select("id", "role").First(&user, userClaims.UserID).Error; err != nil {
+		return Viewer{}
+	}
+	return Viewer{UserID: user.ID, IsModerator: user.IsModerator()}
+}
+
+// Posts scopes a posts query to published posts the viewer may see.
+// Anonymous posts are not filtered by blocks and mutes, otherwise blocking
+// someone would reveal which anonymous posts are theirs.
+func (v Viewer) Posts(db *gorm.DB) *gorm.DB {
+	db = db.Where("posts.status = ?", models.PostStatusPublished)
+	if v.UserID != 0 {
+		db = db.Where("posts.is_anonymous OR posts.author_id NOT IN ("+unwantedAuthors+")", v.UserID, v.UserID, v.UserID)
+	}
+	if v.IsModerator {
+		return db
+	}
+	return db.Where("posts.author_id = ? OR (posts.is_hidden = ? AND posts.author_id NOT IN ("+shadowbannedUsers+"))", v.UserID, false)
+}
+
+// Comments scopes a comments query to the comments the viewer may see
+func (v Viewer) Comments(db *gorm.DB) *gorm.DB {
+	if v.UserID != 0 {
+		db = db.Where("comments.is_anonymous OR comments.author_id NOT IN ("+unwantedAuthors+")", v.UserID, v.UserID, v.UserID)
+	}
+	if v.IsModerator {
+		return db
+	}
+	return db.Where("comments.author_id = ? OR (comments.is_hidden = ? AND comments.author_id NOT IN ("+shadowbannedUsers+"))", v.UserID, false)
+}
+
+// Messages scopes a messages query to the messages the viewer may read in
+// their conversations: their own, and those of other members they have not
+// blocked, been blocked by or muted and who are not shadowbanned. Checking
+// that the viewer is a member is up to the caller.
+func (v Viewer) Messages(db *gorm.DB) *gorm.DB {
+	return db.Where("messages.sender_id = ? OR (messages.sender_id NOT IN ("+unwantedAuthors+") AND messages.sender_id NOT IN ("+shadowbannedUsers+"))",
+		v.UserID, v.UserID, v.UserID, v.UserID)
+}
+
+// CanSeePost is Posts for a single loaded post, used before acting on it.
+// Mutes only declutter listings, so unlike blocks they do not apply here.
+func (v Viewer) CanSeePost(db *gorm.DB, post *models.Post) bool {
+	if !post.IsPublished() {
+		return false
+	}
+	if v.UserID != 0 && post.AuthorID == v.UserID {
+		return true
+	}
+	if v.UserID != 0 && !post.IsAnonymous && Blocked(db, v.UserID, post.AuthorID) {
+		return false
+	}
+	if v.IsModerator {
+		return true
+	}
+	return !post.IsHidden && !Shadowbanned(db, post.AuthorID)
+}
+
+// Blocked reports whether either user has blocked the other. Replies,
+// mentions and direct messages between them are refused.
+func Blocked(db *gorm.DB, a, b uint) bool {
+	var count int64
+	db.Model(&models.UserBlock{}).
+		Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count)
+	return count > 0
+}
+
+// Shadowbanned reports whether the user is shadowbanned. Their actions, such as
+// likes and replies, should not reach anyone else.
+func Shadowbanned(db *gorm.DB, userID uint) bool {
+	var count int64
+	db.Model(&models.User{}).Where("id = ? AND is_shadowbanned", userID).Count(&count)
+	return count > 0
+}
+
+// Notifications scopes a notifications query to the viewer's own
+// notifications, dropping those from users they have since blocked or muted.
+// Moderation notices and notifications about anonymous content are always
+// shown.
+func (v Viewer) Notifications(db *gorm.DB) *gorm.DB {
+	return db.Where("notifications.user_id = ?", v.UserID).
+		Where("notifications.type = ? OR notifications.is_anonymous OR notifications.actor_id NOT IN ("+unwantedAuthors+")",
+			models.NotifyModeration, v.UserID, v.UserID, v.UserID)
+}
+
+// OthersLikes scopes a post_likes query to likes by other users that the
+// viewer would hear about: not by shadowbanned users, and not by users they
+// blocked, were blocked by or muted.
+func (v Viewer) OthersLikes(db *gorm.DB) *gorm.DB {
+	return db.Where("post_likes.user_id <> ? AND post_likes.user_id NOT IN ("+shadowbannedUsers+") AND post_likes.user_id NOT IN ("+unwantedAuthors+")",
+		v.UserID, v.UserID, v.UserID, v.UserID)
+}
+
+// UnwantedAuthors returns the users the viewer blocked, was blocked by or
+// muted, for filtering content outside of SQL queries
+func (v Viewer) UnwantedAuthors(db *gorm.DB) map[uint]bool {
+	unwanted := make(map[uint]bool)
+	if v.UserID == 0 {
+		return unwanted
+	}
+	var ids []uint
+	db.Raw(unwantedAuthors, v.UserID, v.UserID, v.UserID).Scan(&ids)
+	for _, id := range ids {
+		unwanted[id] = true
+	}
+	return unwanted
+}
+
+// AdjustLikeCounts removes likes by shadowbanned users from the like counts
+// of posts, except the viewer's own like. Moderators see the stored counts.
+func (v Viewer) AdjustLikeCounts(db *gorm.DB, posts []models.Post) {
+	if v.IsModerator || len(posts) == 0 {
+		return
+	}
+	postIDs := make([]uint, len(posts))
+	for i := range posts {
+		postIDs[i] = posts[i].ID
+	}
+
+	var hidden []struct {
+		PostID uint
+		Likes  uint
+	}
+	db.Model(&models.PostLike{}).Select("post_id, COUNT(*) AS likes").
+		Where("post_id IN ? AND user_id <> ? AND user_id IN ("+shadowbannedUsers+")", postIDs, v.UserID).
+		Group("post_id").Scan(&hidden)
+
+	hiddenLikes := make(map[uint]uint, len(hidden))
+	for _, h := range hidden {
+		hiddenLikes[h.PostID] = h.Likes
+	}
+	for i := range posts {
+		if n := hiddenLikes[posts[i].ID]; n > 0 {
+			if n > posts[i].Likes {
+				n = posts[i].Likes
+			}
+			posts[i].Likes -= n
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/notify"
+	"WaterlooStar/backend/storage"
+	"WaterlooStar/backend/visibility"
+	"time"
+)
+
+// excerptLength bounds content in payloads; receivers link to the site
+const excerptLength = 280
+
+// PostData is the data of a post.created event. AuthorID is left out for
+// anonymous posts, whose Author is the thread pseudonym.
+type PostData struct {
+	ID          uint       `json:"id"`
+	Title       string     `json:"title"`
+	Section     string     `json:"section"`
+	Author      string     `json:"author"`
+	AuthorID    uint       `json:"author_id,omitempty"`
+	Tags        string     `json:"tags,omitempty"`
+	Excerpt     string     `json:"excerpt"`
+	URL         string     `json:"url"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+// CommentData is the data of a comment.created event, see PostData
+type CommentData struct {
+	ID        uint      `json:"id"`
+	PostID    uint      `json:"post_id"`
+	PostTitle string    `json:"post_title"`
+	Section   string    `json:"section"`
+	Author    string    `json:"author"`
+	AuthorID  uint      `json:"author_id,omitempty"`
+	Excerpt   string    `json:"excerpt"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReportData is the data of a report.created event. Reporters stay private.
+type ReportData struct {
+	ID         uint      `json:"id"`
+	TargetType string    `json:"target_type"`
+	TargetID   uint      `json:"target_id"`
+	Section    string    `json:"section,omitempty"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// UserData is the data of a user.registered event
+type UserData struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PostCreated dispatches post.created for a newly published post. Like live
+// events, posts that are hidden or by shadowbanned users are not sent.
+func PostCreated(post *models.Post) {
+	if !post.IsVisible() || visibility.Shadowbanned(storage.DB, post.AuthorID) {
+		return
+	}
+	data := PostData{
+		ID:          post.ID,
+		Title:       post.Title,
+		Section:     post.Section,
+		Author:      post.Author,
+		Tags:        post.Tags,
+		Excerpt:     notify.Excerpt(post.Content, excerptLength),
+		URL:         notify.PostURL(post.Section, post.ID),
+		PublishedAt: post.PublishedAt,
+	}
+	if !post.IsAnonymous {
+		data.AuthorID = post.AuthorID
+	}
+	Dispatch(storage.DB, models.EventPostCreated, post.Section, data)
+}
+
+// CommentCreated dispatches comment.created for a new comment on post
+func CommentCreated(post *models.Post, comment *models.Comment) {
+	if comment.IsHidden || !post.IsVisible() || visibility.Shadowbanned(storage.DB, comment.AuthorID) {
+		return
+	}
+	data := CommentData{
+		ID:        comment.ID,
+		PostID:    post.ID,
+		PostTitle: post.Title,
+		Section:   post.Section,
+		Author:    comment.Author,
+		Excerpt:   notify.Excerpt(comment.Content, excerptLength),
+		URL:       notify.PostURL(post.Section, post.ID),
+		CreatedAt: comment.CreatedAt,
+	}
+	if !comment.IsAnonymous {
+		data.AuthorID = comment.AuthorID
+	}
+	Dispatch(storage.DB, models.EventCommentCreated, post.Section, data)
+}
+
+// ReportCreated dispatches report.created for a report filed by a user
+func ReportCreated(report *models.Report) {
+	Dispatch(storage.DB, models.EventReportCreated, report.Section, ReportData{
+		ID:         report.ID,
+		TargetType: report.TargetType,
+		TargetID:   report.TargetID,
+		Section:    report.Section,
+		Reason:     report.Reason,
+		CreatedAt:  report.CreatedAt,
+	})
+}
+
+// UserRegistered dispatches user.registered for a new account
+func UserRegistered(user *models.User) {
+	Dispatch(storage.DB, models.EventUserRegistered, "", UserData{ID: user.ID, Username: user.Username, CreatedAt: user.CreatedAt})
+}
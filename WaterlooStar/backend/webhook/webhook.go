@@ -0,0 +1,363 @@
+// Package webhook sends forum events to the external URLs that admins
+// configure. Each event becomes one delivery per matching webhook, sent by
+// the durable job queue and retried with exponential backoff. Webhooks whose
+// endpoint keeps failing are disabled.
+package webhook
+
+import (
+	"WaterlooStar/backend/config"
+	"WaterlooStar/backend/models"
+	"WaterlooStar/backend/queue"
+	"WaterlooStar/backend/storage"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Queue is the job queue for webhook deliveries
+const Queue = "webhook"
+
+const (
+	// maxAttempts is how often a delivery is tried before it fails for good.
+	// It matches the queue's own limit.
+	maxAttempts = 5
+	// timeout bounds one attempt
+	timeout = 10 * time.Second
+	// maxResponseBody is how much of a response is read, and then discarded
+	maxResponseBody = 1024
+)
+
+// Request headers
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload is the JSON body of every delivery
+type Payload struct {
+	ID        string      `json:"id"` // Event ID, the same across webhooks and redeliveries
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// job is the queued work for one delivery
+type job struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+// errInternalAddress refuses a delivery to the forum's own network
+var errInternalAddress = errors.New("destination is a private, loopback or link-local address")
+
+// dialer only connects to public addresses. The check runs on the address
+// actually dialed, after DNS resolution, so that a hostname resolving (or
+// re-resolving) to an internal address cannot reach internal services.
+var dialer = &net.Dialer{
+	Timeout: timeout,
+	Control: func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+			return errInternalAddress
+		}
+		return nil
+	},
+}
+
+// client does not follow redirects: a webhook URL that redirects is
+// misconfigured, and following it could send payloads somewhere unexpected.
+// It ignores proxy settings, which would bypass the dialer's check.
+var client = &http.Client{
+	Timeout: timeout,
+	Transport: &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConnsPerHost: 2,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func init() {
+	queue.Register(Queue, func(payload []byte) error {
+		var j job
+		if err := json.Unmarshal(payload, &j); err != nil {
+			return err
+		}
+		return deliver(storage.DB, j.DeliveryID)
+	})
+}
+
+// publicIP reports whether ip is a public unicast address
+func publicIP(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
+
+// ValidHost reports whether a webhook URL may point at host. Addresses and
+// names that are internal on their face are refused; hostnames are checked
+// again on every delivery, once resolved.
+func ValidHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return publicIP(ip)
+	}
+	return host != ""
+}
+
+// ValidEvent reports whether event is one webhooks can subscribe to
+func ValidEvent(event string) bool {
+	for _, e := range models.WebhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSecret generates a signing secret for a webhook
+func NewSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Webhook-Signature value for a body sent at timestamp:
+// "sha256=" and the hex HMAC-SHA256 of "{timestamp}.{body}" keyed with the
+// webhook's secret. Receivers should recompute it, compare in constant time
+// and reject old timestamps.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// List splits a comma-separated webhook field
+func List(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// matches reports whether the webhook wants the event from section, which
+// is empty for events outside of sections
+func matches(hook *models.Webhook, event, section string) bool {
+	wanted := false
+	for _, e := range List(hook.Events) {
+		wanted = wanted || e == event
+	}
+	sections := List(hook.Sections)
+	if !wanted || section == "" || len(sections) == 0 {
+		return wanted
+	}
+	for _, s := range sections {
+		if s == section {
+			return true
+		}
+	}
+	return false
+}
+
+// Dispatch queues deliveries of an event to every active webhook that wants
+// it. Like notifications, webhooks are best effort: failures are logged so
+// that they never undo the action that caused them.
+func Dispatch(db *gorm.DB, event, section string, data interface{}) {
+	var hooks []models.Webhook
+	if err := db.Where("is_active").Find(&hooks).Error; err != nil {
+		log.Printf("Warning: failed to load webhooks for %s: %v", event, err)
+		return
+	}
+	var matching []models.Webhook
+	for _, hook := range hooks {
+		if matches(&hook, event, section) {
+			matching = append(matching, hook)
+		}
+	}
+	if len(matching) == 0 {
+		return
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		log.Printf("Warning: failed to dispatch %s: %v", event, err)
+		return
+	}
+	payload := Payload{ID: hex.EncodeToString(id), Event: event, CreatedAt: time.Now().UTC(), Data: data}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Warning: failed to dispatch %s: %v", event, err)
+		return
+	}
+	for _, hook := range matching {
+		delivery := models.WebhookDelivery{WebhookID: hook.ID, EventID: payload.ID, Event: event, Payload: string(body)}
+		if err := enqueue(db, &delivery); err != nil {
+			log.Printf("Warning: failed to queue %s for webhook %d: %v", event, hook.ID, err)
+		}
+	}
+}
+
+// Redeliver queues another delivery of a logged one, whatever its outcome
+func Redeliver(db *gorm.DB, original *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	delivery := models.WebhookDelivery{
+		WebhookID:      original.WebhookID,
+		EventID:        original.EventID,
+		Event:          original.Event,
+		Payload:        original.Payload,
+		RedeliveryOfID: &original.ID,
+	}
+	return &delivery, enqueue(db, &delivery)
+}
+
+// enqueue stores a pending delivery and its job together
+func enqueue(db *gorm.DB, delivery *models.WebhookDelivery) error {
+	delivery.Status = models.DeliveryPending
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(delivery).Error; err != nil {
+			return err
+		}
+		return queue.Enqueue(tx, Queue, job{DeliveryID: delivery.ID})
+	})
+}
+
+// deliver makes one attempt at a delivery and logs the outcome. It returns
+// an error to have the queue retry later, and nil once the delivery
+// succeeded or failed for good.
+func deliver(db *gorm.DB, deliveryID uint) error {
+	var delivery models.WebhookDelivery
+	if err := db.First(&delivery, deliveryID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if delivery.Status != models.DeliveryPending {
+		return nil
+	}
+	var hook models.Webhook
+	err := db.First(&hook, delivery.WebhookID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !hook.IsActive) {
+		return db.Model(&delivery).Updates(map[string]interface{}{
+			"status": models.DeliveryFailed,
+			"error":  "webhook deleted or disabled",
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	delivery.Attempts++
+	started := time.Now()
+	status, sendErr := send(&hook, &delivery)
+	updates := map[string]interface{}{
+		"attempts":        delivery.Attempts,
+		"response_status": status,
+		"duration_ms":     time.Since(started).Milliseconds(),
+		"error":           "",
+	}
+
+	if sendErr == nil {
+		now := time.Now()
+		updates["status"] = models.DeliverySucceeded
+		updates["delivered_at"] = now
+		if err := db.Model(&delivery).Updates(updates).Error; err != nil {
+			return err
+		}
+		return db.Model(&models.Webhook{}).Where("id = ? AND consecutive_failures > 0", hook.ID).
+			UpdateColumn("consecutive_failures", 0).Error
+	}
+
+	updates["error"] = sendErr.Error()
+	if delivery.Attempts >= maxAttempts {
+		updates["status"] = models.DeliveryFailed
+	}
+	if err := db.Model(&delivery).Updates(updates).Error; err != nil {
+		return err
+	}
+	recordFailure(db, &hook)
+	if delivery.Attempts >= maxAttempts {
+		log.Printf("Webhook: delivery %d of %s to webhook %d failed for good: %v", delivery.ID, delivery.Event, hook.ID, sendErr)
+		return nil
+	}
+	return sendErr
+}
+
+// send POSTs the signed payload and returns the response status. Any
+// status outside 2xx is an error. The response body is not kept: the
+// delivery log is shown to admins, and must not turn webhooks into a way of
+// reading pages the forum's server can reach.
+func send(hook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := time.Now().Unix()
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "StudentCommunityForum-Webhook/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// Reading the body lets the connection be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// recordFailure counts a failed attempt and disables the webhook once
+// config.WebhookFailureLimit attempts in a row have failed
+func recordFailure(db *gorm.DB, hook *models.Webhook) {
+	err := db.Model(&models.Webhook{}).Where("id = ?", hook.ID).
+		UpdateColumn("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error
+	if err != nil {
+		log.Printf("Webhook: failed to record failure of webhook %d: %v", hook.ID, err)
+		return
+	}
+	result := db.Model(&models.Webhook{}).
+		Where("id = ? AND is_active AND consecutive_failures >= ?", hook.ID, config.WebhookFailureLimit).
+		Updates(map[string]interface{}{"is_active": false, "disabled_at": time.Now()})
+	if result.Error != nil {
+		log.Printf("Webhook: failed to disable webhook %d: %v", hook.ID, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("🔕 Webhook %d (%s) disabled after %d failed deliveries in a row", hook.ID, hook.URL, config.WebhookFailureLimit)
+	}
+}
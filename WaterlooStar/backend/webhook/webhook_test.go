@@ -0,0 +1,152 @@
+package webhook
+
+import (
+	"WaterlooStar/backend/models"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+		want      string
+	}{
+		{"payload", "whsec_test", 1700000000, `{"id":"1"}`, "sha256=11bf4466ea17c3df3fd743af0b435368e16b7a05eb8eced85e8c4670767bdec5"},
+		{"empty body", "whsec_test", 1700000000, "", "sha256=5967f3c560522fa40cf2876ebc3c3a08551dd6959aaade3b413460591895bdcc"},
+		{"empty secret", "", 0, "", "sha256=b849d5a581847b281957065739df36df2463d1977ea8d6e1e4e6cf33fadc68c3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignCoversEveryInput(t *testing.T) {
+	base := Sign("secret", 1700000000, []byte("body"))
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      string
+	}{
+		{"other secret", "secret2", 1700000000, "body"},
+		{"other timestamp", "secret", 1700000001, "body"},
+		{"other body", "secret", 1700000000, "body2"},
+		// The separator keeps the timestamp and body apart
+		{"digit moved into body", "secret", 170000000, "0body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if Sign(tt.secret, tt.timestamp, []byte(tt.body)) == base {
+				t.Error("signature did not change")
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"", 0},
+		{" , ,", 0},
+		{"post.created", 1},
+		{"post.created, comment.created,", 2},
+	}
+	for _, tt := range tests {
+		if got := List(tt.value); len(got) != tt.want {
+			t.Errorf("List(%q) = %q, want %d items", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		events   string
+		sections string
+		event    string
+		section  string
+		want     bool
+	}{
+		{"wanted event", models.EventPostCreated, "", models.EventPostCreated, "housing", true},
+		{"other event", models.EventPostCreated, "", models.EventCommentCreated, "housing", false},
+		{"one of several events", "post.created, comment.created", "", models.EventCommentCreated, "housing", true},
+		{"event name prefix", "post", "", models.EventPostCreated, "", false},
+		{"no events", "", "", models.EventPostCreated, "", false},
+		{"wanted section", models.EventPostCreated, "housing,jobs", models.EventPostCreated, "jobs", true},
+		{"other section", models.EventPostCreated, "housing,jobs", models.EventPostCreated, "events", false},
+		{"section filter with other event", models.EventPostCreated, "housing", models.EventCommentCreated, "housing", false},
+		{"event outside sections", models.EventUserRegistered, "housing", models.EventUserRegistered, "", true},
+		{"section case matters", models.EventPostCreated, "Housing", models.EventPostCreated, "housing", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &models.Webhook{Events: tt.events, Sections: tt.sections}
+			if got := matches(hook, tt.event, tt.section); got != tt.want {
+				t.Errorf("matches(%q, %q) = %v, want %v", tt.event, tt.section, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want bool
+	}{
+		{"example.com", true},
+		{"hooks.example.com.", true},
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"", false},
+		{"localhost", false},
+		{"LOCALHOST.", false},
+		{"api.localhost", false},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"0.0.0.0", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+	}
+	for _, tt := range tests {
+		if got := ValidHost(tt.host); got != tt.want {
+			t.Errorf("ValidHost(%q) = %v, want %v", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached the loopback server")
+	}))
+	defer server.Close()
+
+	resp, err := client.Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || !errors.Is(err, errInternalAddress) {
+		t.Errorf("client.Get(%s) error = %v, want errInternalAddress", server.URL, err)
+	}
+}
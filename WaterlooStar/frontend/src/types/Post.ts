@@ -1,12 +1,55 @@
+export interface Attachment {
+  id: number;
+  created_at: string;
+  uploader_id: number;
+  post_id?: number;
+  comment_id?: number;
+  file_name: string;
+  content_type: string;
+  size: number;
+  sha256: string;
+  has_thumbnail: boolean;
+}
+
+export interface PollOption {
+  id: number;
+  poll_id: number;
+  text: string;
+  position: number;
+  votes: number;
+  voters?: string[];
+}
+
+export interface Poll {
+  id: number;
+  post_id: number;
+  question: string;
+  multiple_choice: boolean;
+  ends_at?: string;
+  anonymous: boolean;
+  hide_results_until_vote: boolean;
+  options: PollOption[];
+  total_votes: number;
+  my_votes: number[];
+  is_closed: boolean;
+  results_hidden: boolean;
+}
+
 export interface Comment {
   id: number;
   created_at: string;
   updated_at: string;
   post_id: number;
   content: string;
+  content_format?: 'plain' | 'markdown';
+  content_html?: string;
   author: string;
   author_id?: number;
+  is_anonymous?: boolean;
+  is_own?: boolean;
+  is_hidden?: boolean;
   likes: number;
+  attachments?: Attachment[];
 }
 
 export interface Post {
@@ -16,11 +59,27 @@ export interface Post {
   section: string;
   title: string;
   content: string;
+  content_format?: 'plain' | 'markdown';
+  content_html?: string;
   author: string;
   author_id: number;
+  is_anonymous?: boolean;
+  is_own?: boolean;
   tags?: string;
   views: number;
   likes: number;
+  status?: 'draft' | 'scheduled' | 'published';
+  publish_at?: string;
+  published_at?: string;
+  is_pinned?: boolean;
+  pin_scope?: 'global' | 'section';
+  pin_order?: number;
+  is_locked?: boolean;
+  is_featured?: boolean;
+  is_hidden?: boolean;
   is_liked?: boolean;
+  is_bookmarked?: boolean;
   comments?: Comment[];
+  attachments?: Attachment[];
+  poll?: Poll;
 }